@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+)
+
+// explainHandler serves the current eviction ranking of the node as JSON.
+func explainHandler(e *pressurecooker.Evicter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		x, err := e.Explain()
+		if err != nil {
+			glog.Errorf("could not rank eviction candidates: %s", err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(x)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/policy"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+)
+
+// the apiserver is queried at least once a minute for the node labels
+const maxAPIAge = 3 * time.Minute
+
+// apiTracker remembers the time of the last request that reached the
+// apiserver and was not answered with a server error. It is shared by all
+// clients, while each client keeps its own transport.
+type apiTracker struct {
+	lastSuccess int64
+}
+
+func (a *apiTracker) wrap(rt http.RoundTripper) http.RoundTripper {
+	return &trackedTransport{tracker: a, next: rt}
+}
+
+// trackedTransport reports the requests of one client to the tracker.
+type trackedTransport struct {
+	tracker *apiTracker
+	next    http.RoundTripper
+}
+
+func (t *trackedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err == nil && resp.StatusCode < 500 {
+		atomic.StoreInt64(&t.tracker.lastSuccess, time.Now().UnixNano())
+	}
+
+	return resp, err
+}
+
+func (a *apiTracker) LastSuccess() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&a.lastSuccess))
+}
+
+type healthCheck struct {
+	name  string
+	check func() error
+}
+
+// healthChecks provides the checks for /livez and /readyz.
+type healthChecks struct {
+	started  time.Time
+	interval time.Duration
+	watcher  *pressurecooker.Watcher
+	api      *apiTracker
+	policies *policy.Watcher
+}
+
+// maxSampleAge allows a few failed or slow ticks before reporting a problem.
+func (h *healthChecks) maxSampleAge() time.Duration {
+	return 3*h.interval + 30*time.Second
+}
+
+// since returns the time since t, or since the start if t is zero.
+func (h *healthChecks) since(t time.Time) time.Duration {
+	if t.IsZero() || t.Before(h.started) {
+		t = h.started
+	}
+
+	return time.Since(t).Round(time.Second)
+}
+
+// watcherRunning fails if the watcher stopped ticking, e.g. because it is
+// stuck.
+func (h *healthChecks) watcherRunning() error {
+	if age := h.since(h.watcher.Status().TickedAt); age > h.maxSampleAge() {
+		return fmt.Errorf("last watcher tick %s ago", age)
+	}
+
+	return nil
+}
+
+func (h *healthChecks) loadSample() error {
+	s := h.watcher.Status()
+	if age := h.since(s.SampledAt); age > h.maxSampleAge() {
+		if s.LastError != "" {
+			return fmt.Errorf("last successful load sample %s ago, last error: %s", age, s.LastError)
+		}
+		return fmt.Errorf("last successful load sample %s ago", age)
+	}
+
+	return nil
+}
+
+func (h *healthChecks) apiServer() error {
+	if age := h.since(h.api.LastSuccess()); age > maxAPIAge {
+		return fmt.Errorf("last successful apiserver request %s ago", age)
+	}
+
+	return nil
+}
+
+func (h *healthChecks) informers() error {
+	if h.policies != nil && !h.policies.Synced() {
+		return fmt.Errorf("pressure policies are not synced")
+	}
+
+	return nil
+}
+
+func (h *healthChecks) livez() []healthCheck {
+	return []healthCheck{
+		{name: "watcher", check: h.watcherRunning},
+	}
+}
+
+func (h *healthChecks) readyz() []healthCheck {
+	return []healthCheck{
+		{name: "watcher", check: h.watcherRunning},
+		{name: "load-sample", check: h.loadSample},
+		{name: "apiserver", check: h.apiServer},
+		{name: "informers", check: h.informers},
+	}
+}
+
+// healthHandler runs all checks and lists their results like the
+// Kubernetes components do. It fails with 503 if any check fails.
+func healthHandler(name string, checks []healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		failed := false
+
+		for _, c := range checks {
+			if err := c.check(); err != nil {
+				failed = true
+				fmt.Fprintf(&b, "[-]%s failed: %s\n", c.name, err.Error())
+			} else {
+				fmt.Fprintf(&b, "[+]%s ok\n", c.name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+
+		if failed {
+			fmt.Fprintf(&b, "%s check failed\n", name)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			fmt.Fprintf(&b, "%s check passed\n", name)
+		}
+
+		w.Write([]byte(b.String()))
+	}
+}
@@ -1,87 +1,154 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/procfs"
 	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/controller"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/notify"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/policy"
 	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/tracing"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 var (
-	prometheusNamespace       = "pressurecooker"
-	pressureThresholdExceeded = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: prometheusNamespace,
-		Name:      "pressure_threshold_exceeded",
-		Help:      "cpu pressure is currently above (1) or below (0) threshold",
-	})
-	pressureThresholdExceededTotal = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: prometheusNamespace,
-		Name:      "pressure_threshold_exceeded_total",
-		Help:      "number of times the pressure threshold was exceeded",
-	})
-	pressureRecoveredTotal = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: prometheusNamespace,
-		Name:      "pressure_recovered_total",
-		Help:      "number of times the pressure on the node recovered",
-	})
-	pressureMode = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	prometheusNamespace = "pressurecooker"
+	pressureMode        = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: prometheusNamespace,
 		Name:      "mode",
 		Help:      "pressurecooker mode",
 	}, []string{"mode"})
-	pressureEnabled = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: prometheusNamespace,
-		Name:      "enabled",
-		Help:      "pressurecooker is enabled (1) or disabled (0)",
-	})
 )
 
+func newRegistry() *prometheus.Registry {
+	r := prometheus.NewRegistry()
+
+	r.MustRegister(collectors.NewGoCollector())
+	r.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	r.MustRegister(pressureMode)
+	controller.RegisterMetrics(r)
+	pressurecooker.RegisterMetrics(r)
+
+	return r
+}
+
 func main() {
-	prometheus.MustRegister(pressureThresholdExceeded)
-	prometheus.MustRegister(pressureThresholdExceededTotal)
-	prometheus.MustRegister(pressureRecoveredTotal)
-	prometheus.MustRegister(pressureEnabled)
+	defer glog.Flush()
 
-	var f config.StartupFlags
+	registry := newRegistry()
 
-	flag.StringVar(&f.KubeConfig, "kubeconfig", "", "file path to kubeconfig")
-	flag.Float64Var(&f.PressureTaintThreshold, "taint-threshold", 25, "pressure threshold value to taint the node")
-	flag.Float64Var(&f.PressureEvictThreshold, "evict-threshold", 50, "pressure threshold value to evict pods")
-	flag.Float64Var(&f.LoadTaintThreshold, "load-taint-threshold", 25, "load average threshold value to taint the node - used if pressure is not available")
-	flag.Float64Var(&f.LoadEvictThreshold, "load-evict-threshold", 50, "load average threshold value to evict pods - used if pressure is not available")
-	flag.StringVar(&f.EvictBackoff, "evict-backoff", "10m", "time to wait between evicting Pods")
-	flag.StringVar(&f.MinPodAge, "min-pod-age", "5m", "minimum age of Pods to be evicted")
-	flag.StringVar(&f.NodeName, "node-name", "", "current node name")
-	flag.IntVar(&f.MetricsPort, "metrics-port", 8080, "port for prometheus metrics endpoint")
+	var f config.StartupFlags
+	f.Register(flag.CommandLine, config.Default())
 	flag.Parse()
 
 	if f.NodeName == "" {
 		panic("-node-name not set")
 	}
 
+	if f.OTLPEndpoint != "" {
+		shutdownTracing, err := tracing.Setup(f.OTLPEndpoint, f.OTLPInsecure, f.TraceSampleRatio, f.NodeName)
+		if err != nil {
+			panic(err)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				glog.Errorf("could not flush traces: %s", err.Error())
+			}
+		}()
+	}
+
+	closeChan := make(chan struct{})
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+
+	go func() {
+		s := <-sigChan
+
+		glog.Infof("received signal %s", s)
+
+		close(closeChan)
+	}()
+
 	cfg, err := loadKubernetesConfig(f)
 	if err != nil {
 		panic(err)
 	}
 
+	api := &apiTracker{}
+	if wrap := cfg.WrapTransport; wrap != nil {
+		cfg.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+			return api.wrap(wrap(rt))
+		}
+	} else {
+		cfg.WrapTransport = api.wrap
+	}
+
 	c, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		panic(err)
 	}
 
+	overrides := config.NewOverrides(flag.CommandLine, f)
+	base := config.Default()
+	var reloader *config.Reloader
+	var policies *policy.Watcher
+	switch {
+	case f.Policies:
+		if f.ConfigFile != "" {
+			glog.Exitf("-policies can not be combined with -config")
+		}
+		// policies are the only source of truth, so flags must not override them
+		if changes, err := overrides.Changes(); err != nil || len(changes) > 0 {
+			glog.Exitf("-policies can not be combined with configuration flags: %v %v", changes, err)
+		}
+		overrides = config.Overrides{}
+
+		dc, err := dynamic.NewForConfig(cfg)
+		if err != nil {
+			panic(err)
+		}
+
+		policies = policy.NewWatcher(dc, f.NodeName)
+		if err := policies.Start(closeChan); err != nil {
+			panic(err)
+		}
+		if base, err = policies.Config(); err != nil {
+			panic(err)
+		}
+	case f.ConfigFile != "":
+		reloader = config.NewReloader(f.ConfigFile, overrides)
+		if base, err = reloader.Load(); err != nil {
+			glog.Exitf("invalid configuration: %s", err.Error())
+		}
+	default:
+		if err := base.ValidateAll(overrides); err != nil {
+			glog.Exitf("invalid configuration: %s", err.Error())
+		}
+	}
+
 	fs, err := procfs.NewDefaultFS()
 
 	if err != nil {
@@ -89,154 +156,133 @@ func main() {
 	}
 
 	var lg pressurecooker.LoadGetter
-	var taintThreshold float64
-	var evictThreshold float64
-	if _, err := fs.PSIStatsForResource("cpu"); err == nil {
+	_, err = fs.PSIStatsForResource("cpu")
+	usePSI := err == nil
+	if usePSI {
 		lg = &pressurecooker.PressureLoadGetter{ProcFS: fs}
-		taintThreshold = f.PressureTaintThreshold
-		evictThreshold = f.PressureEvictThreshold
 		pressureMode.WithLabelValues("psi").Set(1)
 	} else {
 		lg = &pressurecooker.LoadAvgLoadGetter{ProcFS: fs}
-		taintThreshold = f.LoadTaintThreshold
-		evictThreshold = f.LoadEvictThreshold
 		pressureMode.WithLabelValues("loadavg").Set(1)
 	}
 
-	w, err := pressurecooker.NewWatcher(taintThreshold, lg)
-	if err != nil {
-		panic(err)
+	if f.RecordLoad != "" {
+		// one sample is about 150 bytes, about 0.86MB per day at the default
+		// interval, so the default of 100MB lasts about 4 months
+		rf, err := pressurecooker.NewRotatingFile(f.RecordLoad, int64(f.RecordLoadMaxSizeMB)<<20, f.RecordLoadMaxBackups)
+		if err != nil {
+			panic(err)
+		}
+		defer rf.Close()
+		lg = pressurecooker.NewRecordingLoadGetter(lg, f.NodeName, rf)
 	}
 
-	t, err := pressurecooker.NewTainter(c, f.NodeName)
-	if err != nil {
-		panic(err)
+	var auditWriters []io.Writer
+	if f.AuditLog != "" {
+		rf, err := pressurecooker.NewRotatingFile(f.AuditLog, int64(f.AuditLogMaxSizeMB)<<20, f.AuditLogMaxBackups)
+		if err != nil {
+			panic(err)
+		}
+		defer rf.Close()
+		auditWriters = append(auditWriters, rf)
+	}
+	if f.AuditLogStdout {
+		auditWriters = append(auditWriters, os.Stdout)
+	}
+	// nil unless -audit-log or -audit-log-stdout is set
+	var auditLog *pressurecooker.AuditLog
+	if len(auditWriters) > 0 {
+		auditLog = pressurecooker.NewAuditLog(f.NodeName, auditWriters...)
 	}
 
-	e, err := pressurecooker.NewEvicter(c, evictThreshold, f.NodeName, f.EvictBackoff, f.MinPodAge)
-	if err != nil {
-		panic(err)
+	opts := controller.Options{
+		NodeName:            f.NodeName,
+		UsePSI:              usePSI,
+		Base:                base,
+		Overrides:           overrides,
+		Policies:            policies,
+		KillSwitchNamespace: f.KillSwitchNamespace,
+		KillSwitchName:      f.KillSwitchName,
+		AuditLog:            auditLog,
 	}
 
-	closeChan := make(chan struct{})
+	if f.WebhookURLs != "" || f.WebhookNamespaces {
+		format, err := notify.ParseFormat(f.WebhookFormat)
+		if err != nil {
+			glog.Exitf("invalid -webhook-format: %s", err.Error())
+		}
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+		var urls []string
+		for _, url := range strings.Split(f.WebhookURLs, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				urls = append(urls, url)
+			}
+		}
 
-	go func() {
-		s := <-sigChan
+		var allowlist []*url.URL
+		if f.WebhookNamespaces {
+			if allowlist, err = notify.ParseAllowlist(f.WebhookAllowlist); err != nil {
+				glog.Exitf("invalid -webhook-namespace-allowlist: %s", err.Error())
+			}
+			if len(allowlist) == 0 {
+				glog.Exitf("-webhook-namespace-routing requires -webhook-namespace-allowlist")
+			}
+		}
 
-		glog.Infof("received signal %s", s)
+		webhook := notify.NewWebhook(c, urls, format, allowlist)
+		go webhook.Run()
+		// runs after the controller's deferred shutdown, so the untaint
+		// notification is still delivered
+		defer webhook.Close(10 * time.Second)
+		opts.Notifier = webhook
+	}
 
-		close(closeChan)
-	}()
+	ctrl, err := controller.NewController(c, lg, clock.RealClock{}, opts)
+	if err != nil {
+		glog.Exitf("could not start: %s", err.Error())
+	}
+
+	health := &healthChecks{
+		started:  time.Now(),
+		interval: ctrl.Watcher().TickerInterval,
+		watcher:  ctrl.Watcher(),
+		api:      api,
+		policies: policies,
+	}
 
 	go func() {
 		http.HandleFunc("/-/health", func(w http.ResponseWriter, r *http.Request) {
 			w.Header().Set("Content-Type", "text/plain")
 			w.Write([]byte("OK\n"))
 		})
-		http.Handle("/metrics", promhttp.Handler())
+		http.Handle("/livez", healthHandler("livez", health.livez()))
+		http.Handle("/readyz", healthHandler("readyz", health.readyz()))
+		http.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		http.Handle("/explain", explainHandler(ctrl.Evicter()))
+		http.Handle("/status", ctrl.Status())
 		http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", f.MetricsPort), nil)
 	}()
 
-	isTainted, err := t.IsNodeTainted()
-	if err != nil {
+	if err := ctrl.Start(); err != nil {
 		panic(err)
 	}
 
-	isDisabled, err := t.IsPressurecookerDisabled()
-	lastDisabledCheck := time.Now()
-	if err != nil {
-		panic(err)
-	}
-	if isDisabled {
-		pressureEnabled.Set(0)
-	} else {
-		pressureEnabled.Set(1)
-	}
+	// the taint is handed over once the main loop stops
+	defer func() {
+		if err := ctrl.Shutdown(); err != nil {
+			glog.Errorf("could not hand over taint on shutdown: %s", err.Error())
+		}
+	}()
 
-	w.SetAsHigh(isTainted)
-	if isTainted {
-		pressureThresholdExceeded.Set(1)
-	} else {
-		pressureThresholdExceeded.Set(0)
+	var reloads <-chan config.Config
+	if reloader != nil {
+		reloads = reloader.Run(closeChan)
 	}
-
-	exc, dec, errs := w.Run(closeChan)
-	for {
-		select {
-		case evt, ok := <-exc:
-			if !ok {
-				glog.Infof("exceedance channel closed; stopping")
-				return
-			}
-
-			if time.Since(lastDisabledCheck) > 1*time.Minute {
-				if disabled, err := t.IsPressurecookerDisabled(); err == nil {
-					isDisabled = disabled
-					if isDisabled {
-						pressureEnabled.Set(0)
-					} else {
-						pressureEnabled.Set(1)
-					}
-				} else {
-					glog.Errorf("could not check pressurecooker.enabled: %s", err.Error())
-				}
-				lastDisabledCheck = time.Now()
-			}
-			if isDisabled && isTainted {
-				if err := t.UntaintNode(pressurecooker.ThresholdEvent{}); err != nil {
-					glog.Errorf("error while untainting node: %s", err.Error())
-				} else {
-					isTainted = false
-				}
-			}
-
-			if isDisabled {
-				glog.Infof("pressurecooker disabled, pressure: %v", evt.String())
-				continue
-			}
-
-			if isTainted {
-				if _, err := e.EvictPod(evt); err != nil {
-					glog.Errorf("error while evicting pod: %s", err.Error())
-				}
-				continue
-			}
-
-			glog.Infof("5 minute pressure average exceeded threshold, %v", evt.Load)
-
-			if err := t.TaintNode(evt); err != nil {
-				glog.Errorf("error while tainting node: %s", err.Error())
-			} else {
-				isTainted = true
-				pressureThresholdExceeded.Set(1)
-				pressureThresholdExceededTotal.Inc()
-			}
-		case evt, ok := <-dec:
-			if !ok {
-				glog.Infof("deceedance channel closed; stopping")
-				return
-			}
-
-			if !isTainted {
-				continue
-			}
-
-			glog.Infof("pressure deceeded threshold, %s", evt.String())
-			if err := t.UntaintNode(evt); err != nil {
-				glog.Errorf("error while removing taint from node: %s", err.Error())
-			} else {
-				isTainted = false
-				pressureThresholdExceeded.Set(0)
-				pressureRecoveredTotal.Inc()
-			}
-
-		case err := <-errs:
-			glog.Errorf("error while polling for status updates: %s", err.Error())
-		}
+	if policies != nil {
+		reloads = policies.Run(closeChan)
 	}
+
+	ctrl.Run(closeChan, reloads)
 }
 
 func loadKubernetesConfig(f config.StartupFlags) (*rest.Config, error) {
@@ -0,0 +1,124 @@
+// Command replay replays a load trace recorded with -record-load against a
+// fake cluster and prints what pressurecooker did. The audit log is written
+// to stdout.
+//
+//	replay -trace load.jsonl [-config config.yaml] [-pods pods.yaml]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/controller/controllertest"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// logTB reports harness failures through glog.
+type logTB struct{}
+
+func (logTB) Helper() {}
+
+func (logTB) Fatalf(format string, args ...interface{}) {
+	glog.Exitf(format, args...)
+}
+
+func (logTB) Logf(format string, args ...interface{}) {
+	glog.Warningf(format, args...)
+}
+
+func main() {
+	var tracePath, configPath, podsPath string
+	flag.StringVar(&tracePath, "trace", "", "load trace written by -record-load")
+	flag.StringVar(&configPath, "config", "", "configuration file of the node (defaults if empty)")
+	flag.StringVar(&podsPath, "pods", "", "pods of the node as a PodList or List, e.g. from kubectl get pods -o yaml")
+	flag.Parse()
+
+	if tracePath == "" {
+		glog.Exitf("-trace is required")
+	}
+
+	f, err := os.Open(tracePath)
+	if err != nil {
+		glog.Exitf("could not open trace: %s", err.Error())
+	}
+	trace, err := pressurecooker.NewReplayLoadGetter(f)
+	f.Close()
+	if err != nil {
+		glog.Exitf("could not read trace %s: %s", tracePath, err.Error())
+	}
+
+	conf := config.Default()
+	if configPath != "" {
+		if conf, err = config.NewReloader(configPath, config.Overrides{}).Load(); err != nil {
+			glog.Exitf("could not load configuration: %s", err.Error())
+		}
+	}
+
+	var pods []runtime.Object
+	if podsPath != "" {
+		if pods, err = readPods(podsPath); err != nil {
+			glog.Exitf("could not read pods %s: %s", podsPath, err.Error())
+		}
+	}
+
+	nodeName := controllertest.NodeName
+	if samples := trace.Samples(); len(samples) > 0 && samples[0].Node != "" {
+		nodeName = samples[0].Node
+	}
+
+	h := controllertest.NewWithLoad(logTB{}, conf, controllertest.NewNode(nodeName), trace, pods...)
+	h.Replay(trace)
+
+	if _, err := os.Stdout.Write(h.Audit.Bytes()); err != nil {
+		glog.Exitf("could not write audit log: %s", err.Error())
+	}
+	for _, r := range h.Controller.Evicter().History() {
+		fmt.Fprintf(os.Stderr, "%s %s %s\n", r.Time.Format(time.RFC3339), r.Action, r.Pod)
+	}
+	fmt.Fprintf(os.Stderr, "tainted at the end of the trace: %t\n", h.Tainted())
+}
+
+// readPods decodes a PodList, or a List of pods, in YAML or JSON.
+func readPods(path string) ([]runtime.Object, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := scheme.Codecs.UniversalDeserializer()
+	obj, _, err := decoder.Decode(data, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var pods []runtime.Object
+	switch list := obj.(type) {
+	case *v1.PodList:
+		for i := range list.Items {
+			pods = append(pods, &list.Items[i])
+		}
+	case *v1.List:
+		for _, item := range list.Items {
+			pod, _, err := decoder.Decode(item.Raw, nil, nil)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := pod.(*v1.Pod); !ok {
+				return nil, fmt.Errorf("unexpected %T in list", pod)
+			}
+			pods = append(pods, pod)
+		}
+	default:
+		return nil, fmt.Errorf("expected a PodList or List, got %T", obj)
+	}
+
+	return pods, nil
+}
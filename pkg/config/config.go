@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	APIVersion = "pressurecooker/v1alpha1"
+	Kind       = "Configuration"
+)
+
+// Config is the versioned configuration file format. Every field is
+// optional; omitted fields keep the values from Default.
+type Config struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+
+	// how often the load is sampled
+	Interval metav1.Duration `json:"interval"`
+	// simulate all actions, see TaintConfig.DryRun and EvictionConfig.DryRun
+	DryRun bool `json:"dryRun"`
+
+	Thresholds Thresholds                    `json:"thresholds"`
+	Taint      TaintConfig                   `json:"taint"`
+	Eviction   EvictionConfig                `json:"eviction"`
+	Scoring    pressurecooker.ScoringWeights `json:"scoring"`
+	Exclusions pressurecooker.Exclusions     `json:"exclusions"`
+
+	// the first profile matching the node's labels is applied on top
+	Profiles []Profile `json:"profiles,omitempty"`
+}
+
+// Thresholds per load source; loadavg is used if pressure is not available.
+type Thresholds struct {
+	PSI     ThresholdPair `json:"psi"`
+	LoadAvg ThresholdPair `json:"loadavg"`
+}
+
+type ThresholdPair struct {
+	Taint float64 `json:"taint"`
+	Evict float64 `json:"evict"`
+}
+
+type TaintConfig struct {
+	Enabled bool           `json:"enabled"`
+	Key     string         `json:"key"`
+	Value   string         `json:"value"`
+	Effect  v1.TaintEffect `json:"effect"`
+	DryRun  bool           `json:"dryRun"`
+	// what to do with an existing taint when pressurecooker stops
+	OnShutdown     pressurecooker.ShutdownAction `json:"onShutdown"`
+	ShutdownExpiry metav1.Duration               `json:"shutdownExpiry"`
+}
+
+type EvictionConfig struct {
+	Enabled       bool                         `json:"enabled"`
+	Action        pressurecooker.EvictAction   `json:"action"`
+	SelectionMode pressurecooker.SelectionMode `json:"selectionMode"`
+	// select pods by age if the cpu usage can not be read in cpu-usage mode,
+	// instead of not evicting at all
+	SelectionFallback bool            `json:"selectionFallback"`
+	Backoff           metav1.Duration `json:"backoff"`
+	MinPodAge         metav1.Duration `json:"minPodAge"`
+	DryRun            bool            `json:"dryRun"`
+	// namespace of the budget and disruption leases
+	LeaseNamespace string           `json:"leaseNamespace"`
+	Resize         ResizeConfig     `json:"resize"`
+	Budget         BudgetConfig     `json:"budget"`
+	Disruptions    DisruptionConfig `json:"disruptions"`
+}
+
+type ResizeConfig struct {
+	Factor float64           `json:"factor"`
+	MaxCPU resource.Quantity `json:"maxCPU"`
+}
+
+type BudgetConfig struct {
+	LeaseName          string  `json:"leaseName"`
+	ClusterPerMinute   float64 `json:"clusterPerMinute"`
+	NamespacePerMinute float64 `json:"namespacePerMinute"`
+}
+
+type DisruptionConfig struct {
+	LeaseName       string          `json:"leaseName"`
+	Window          metav1.Duration `json:"window"`
+	MaxPerOwner     int             `json:"maxPerOwner"`
+	MaxPerNamespace int             `json:"maxPerNamespace"`
+}
+
+func Default() Config {
+	taint := pressurecooker.DefaultTaint()
+
+	return Config{
+		APIVersion: APIVersion,
+		Kind:       Kind,
+		Interval:   metav1.Duration{Duration: 15 * time.Second},
+		Thresholds: Thresholds{
+			PSI:     ThresholdPair{Taint: 25, Evict: 50},
+			LoadAvg: ThresholdPair{Taint: 25, Evict: 50},
+		},
+		Taint: TaintConfig{
+			Enabled: true,
+			Key:     taint.Key,
+			Value:   taint.Value,
+			Effect:  taint.Effect,
+
+			OnShutdown:     pressurecooker.ShutdownActionRemove,
+			ShutdownExpiry: metav1.Duration{Duration: 10 * time.Minute},
+		},
+		Eviction: EvictionConfig{
+			Enabled:        true,
+			Action:         pressurecooker.EvictActionEvict,
+			SelectionMode:  pressurecooker.SelectionModeAge,
+			Backoff:        metav1.Duration{Duration: 10 * time.Minute},
+			MinPodAge:      metav1.Duration{Duration: 5 * time.Minute},
+			LeaseNamespace: "kube-system",
+			Resize: ResizeConfig{
+				Factor: 1.5,
+				MaxCPU: resource.MustParse("4"),
+			},
+			Budget: BudgetConfig{
+				LeaseName: "pressurecooker-eviction-budget",
+			},
+			Disruptions: DisruptionConfig{
+				LeaseName: "pressurecooker-disruptions",
+				Window:    metav1.Duration{Duration: time.Hour},
+			},
+		},
+		Scoring:    pressurecooker.DefaultScoringWeights(),
+		Exclusions: pressurecooker.DefaultExclusions(),
+	}
+}
+
+// Parse decodes a YAML or JSON configuration on top of the defaults.
+// Unknown and duplicate fields are rejected.
+func Parse(data []byte) (Config, error) {
+	var meta struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+	}
+	if err := yaml.Unmarshal(data, &meta); err != nil {
+		return Config{}, err
+	}
+	if meta.APIVersion != APIVersion || meta.Kind != Kind {
+		return Config{}, fmt.Errorf("unsupported configuration %s %q, expected %s %q", meta.APIVersion, meta.Kind, APIVersion, Kind)
+	}
+
+	c := Default()
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+func (t TaintConfig) ToTaint() v1.Taint {
+	return v1.Taint{
+		Key:    t.Key,
+		Value:  t.Value,
+		Effect: t.Effect,
+	}
+}
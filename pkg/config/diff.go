@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Diff lists the fields that differ between old and new, one
+// "path: old -> new" entry per field.
+func Diff(old, new Config) []string {
+	oldFields := flatten(old)
+	newFields := flatten(new)
+
+	var changes []string
+	for path, o := range oldFields {
+		if n, ok := newFields[path]; !ok {
+			changes = append(changes, fmt.Sprintf("%s: %s -> <unset>", path, o))
+		} else if n != o {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", path, o, n))
+		}
+	}
+	for path, n := range newFields {
+		if _, ok := oldFields[path]; !ok {
+			changes = append(changes, fmt.Sprintf("%s: <unset> -> %s", path, n))
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// flatten maps the JSON paths of all leaf values of c to their JSON encoding.
+func flatten(c Config) map[string]string {
+	raw, err := json.Marshal(&c)
+	if err != nil {
+		panic(err)
+	}
+
+	var tree interface{}
+	if err := json.Unmarshal(raw, &tree); err != nil {
+		panic(err)
+	}
+
+	fields := make(map[string]string)
+	flattenInto(fields, "", tree)
+	return fields
+}
+
+func flattenInto(fields map[string]string, prefix string, v interface{}) {
+	if m, ok := v.(map[string]interface{}); ok {
+		for k, child := range m {
+			path := k
+			if prefix != "" {
+				path = prefix + "." + k
+			}
+			flattenInto(fields, path, child)
+		}
+		return
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	fields[prefix] = string(raw)
+}
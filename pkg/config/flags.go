@@ -1,13 +1,175 @@
 package config
 
+import (
+	"flag"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
 type StartupFlags struct {
 	KubeConfig             string
+	ConfigFile             string
+	Policies               bool
 	PressureTaintThreshold float64
 	PressureEvictThreshold float64
 	LoadTaintThreshold     float64
 	LoadEvictThreshold     float64
-	EvictBackoff           string
-	MinPodAge              string
+	EvictBackoff           time.Duration
+	MinPodAge              time.Duration
+	SelectionMode          string
+	SelectionFallback      bool
+	EvictAction            string
+	ResizeFactor           float64
+	ResizeMaxCPU           string
+	DryRun                 bool
+	DryRunTaint            bool
+	DryRunEvict            bool
+	ShutdownTaint          string
+	ShutdownTaintExpiry    time.Duration
+	BudgetNamespace        string
+	BudgetLeaseName        string
+	ClusterEvictionsPerMin float64
+	NSEvictionsPerMin      float64
+	DisruptionLeaseName    string
+	DisruptionWindow       time.Duration
+	MaxEvictionsPerOwner   int
+	MaxEvictionsPerNS      int
+	KillSwitchNamespace    string
+	KillSwitchName         string
+	AuditLog               string
+	AuditLogStdout         bool
+	AuditLogMaxSizeMB      int
+	AuditLogMaxBackups     int
+	WebhookURLs            string
+	WebhookFormat          string
+	WebhookNamespaces      bool
+	WebhookAllowlist       string
+	OTLPEndpoint           string
+	OTLPInsecure           bool
+	TraceSampleRatio       float64
+	RecordLoad             string
+	RecordLoadMaxSizeMB    int
+	RecordLoadMaxBackups   int
 	NodeName               string
 	MetricsPort            int
 }
+
+// Register adds all flags to fs, using the values of d as defaults.
+func (f *StartupFlags) Register(fs *flag.FlagSet, d Config) {
+	fs.StringVar(&f.KubeConfig, "kubeconfig", "", "file path to kubeconfig")
+	fs.StringVar(&f.ConfigFile, "config", "", "file path to a YAML or JSON configuration file; flags that are set explicitly take precedence")
+	fs.BoolVar(&f.Policies, "policies", false, "take the configuration from PressurePolicy objects instead of flags or -config")
+	fs.Float64Var(&f.PressureTaintThreshold, "taint-threshold", d.Thresholds.PSI.Taint, "pressure threshold value to taint the node")
+	fs.Float64Var(&f.PressureEvictThreshold, "evict-threshold", d.Thresholds.PSI.Evict, "pressure threshold value to evict pods")
+	fs.Float64Var(&f.LoadTaintThreshold, "load-taint-threshold", d.Thresholds.LoadAvg.Taint, "load average threshold value to taint the node - used if pressure is not available")
+	fs.Float64Var(&f.LoadEvictThreshold, "load-evict-threshold", d.Thresholds.LoadAvg.Evict, "load average threshold value to evict pods - used if pressure is not available")
+	fs.DurationVar(&f.EvictBackoff, "evict-backoff", d.Eviction.Backoff.Duration, "time to wait between evicting Pods")
+	fs.DurationVar(&f.MinPodAge, "min-pod-age", d.Eviction.MinPodAge.Duration, "minimum age of Pods to be evicted")
+	fs.StringVar(&f.SelectionMode, "selection-mode", string(d.Eviction.SelectionMode), "how to pick pods for eviction: age (oldest first) or cpu-usage (highest cpu usage over request first)")
+	fs.BoolVar(&f.SelectionFallback, "selection-fallback", d.Eviction.SelectionFallback, "with -selection-mode=cpu-usage, select pods by age if the cpu usage can not be read instead of not evicting")
+	fs.StringVar(&f.EvictAction, "evict-action", string(d.Eviction.Action), "what to do with the selected pod: evict, or resize (raise its cpu requests in place, evicting if that is not possible)")
+	fs.Float64Var(&f.ResizeFactor, "resize-factor", d.Eviction.Resize.Factor, "factor to raise cpu requests by with -evict-action=resize")
+	fs.StringVar(&f.ResizeMaxCPU, "resize-max-cpu", d.Eviction.Resize.MaxCPU.String(), "upper bound for the cpu request of a single container with -evict-action=resize")
+	fs.BoolVar(&f.DryRun, "dry-run", d.DryRun, "log and report taints and evictions without changing nodes or pods")
+	fs.BoolVar(&f.DryRunTaint, "dry-run-taint", d.Taint.DryRun, "log and report taints without changing the node")
+	fs.BoolVar(&f.DryRunEvict, "dry-run-evict", d.Eviction.DryRun, "log and report evictions without evicting or resizing pods")
+	fs.StringVar(&f.ShutdownTaint, "shutdown-taint", string(d.Taint.OnShutdown), "what to do with the taint on shutdown: remove, expire (leave it for the next instance until -shutdown-taint-expiry has passed) or keep")
+	fs.DurationVar(&f.ShutdownTaintExpiry, "shutdown-taint-expiry", d.Taint.ShutdownExpiry.Duration, "time after which a taint left behind with -shutdown-taint=expire is removed on startup")
+	fs.Float64Var(&f.ClusterEvictionsPerMin, "cluster-evictions-per-minute", d.Eviction.Budget.ClusterPerMinute, "maximum evictions per minute across all nodes, 0 for unlimited")
+	fs.Float64Var(&f.NSEvictionsPerMin, "namespace-evictions-per-minute", d.Eviction.Budget.NamespacePerMinute, "maximum evictions per minute and namespace across all nodes, 0 for unlimited")
+	fs.StringVar(&f.BudgetNamespace, "budget-namespace", d.Eviction.LeaseNamespace, "namespace of the lease holding the cluster-wide eviction budget")
+	fs.StringVar(&f.BudgetLeaseName, "budget-lease", d.Eviction.Budget.LeaseName, "name of the lease holding the cluster-wide eviction budget")
+	fs.IntVar(&f.MaxEvictionsPerOwner, "max-evictions-per-owner", d.Eviction.Disruptions.MaxPerOwner, "maximum evictions of pods of the same workload within -disruption-window across all nodes, 0 for unlimited")
+	fs.IntVar(&f.MaxEvictionsPerNS, "max-evictions-per-namespace", d.Eviction.Disruptions.MaxPerNamespace, "maximum evictions in the same namespace within -disruption-window across all nodes, 0 for unlimited")
+	fs.DurationVar(&f.DisruptionWindow, "disruption-window", d.Eviction.Disruptions.Window.Duration, "window for -max-evictions-per-owner and -max-evictions-per-namespace")
+	fs.StringVar(&f.DisruptionLeaseName, "disruption-lease", d.Eviction.Disruptions.LeaseName, "name of the lease (in -budget-namespace) holding the recent eviction history")
+	fs.StringVar(&f.KillSwitchNamespace, "kill-switch-namespace", "kube-system", "namespace of the kill switch ConfigMap")
+	fs.StringVar(&f.KillSwitchName, "kill-switch-configmap", "pressurecooker", "name of the ConfigMap that can disable pressurecooker on all nodes, empty to disable")
+	fs.StringVar(&f.AuditLog, "audit-log", "", "file path of the JSON lines audit log, empty to disable")
+	fs.BoolVar(&f.AuditLogStdout, "audit-log-stdout", false, "write the JSON lines audit log to stdout")
+	fs.IntVar(&f.AuditLogMaxSizeMB, "audit-log-max-size", 100, "size in megabytes at which -audit-log is rotated")
+	fs.IntVar(&f.AuditLogMaxBackups, "audit-log-max-backups", 5, "number of rotated audit log files to keep")
+	fs.StringVar(&f.WebhookURLs, "webhook-urls", "", "comma separated list of URLs to post taint and eviction notifications to")
+	fs.StringVar(&f.WebhookFormat, "webhook-format", "json", "payload format for -webhook-urls: json or slack")
+	fs.BoolVar(&f.WebhookNamespaces, "webhook-namespace-routing", false, "also notify the webhook set in the pressurecooker/webhook-url annotation of the evicted pod's namespace, if it is in -webhook-namespace-allowlist")
+	fs.StringVar(&f.WebhookAllowlist, "webhook-namespace-allowlist", "", "comma separated list of URL prefixes, e.g. https://hooks.slack.com/services/, that -webhook-namespace-routing may post to")
+	fs.StringVar(&f.OTLPEndpoint, "otlp-endpoint", "", "host:port of an OTLP/HTTP collector to export traces to, empty to disable tracing")
+	fs.BoolVar(&f.OTLPInsecure, "otlp-insecure", false, "export traces over plain HTTP instead of HTTPS")
+	fs.Float64Var(&f.TraceSampleRatio, "trace-sample-ratio", 1, "fraction of watcher ticks to trace")
+	fs.StringVar(&f.RecordLoad, "record-load", "", "file path to record every load sample to, for replaying incidents; empty to disable")
+	fs.IntVar(&f.RecordLoadMaxSizeMB, "record-load-max-size", 100, "size in megabytes at which -record-load is rotated")
+	fs.IntVar(&f.RecordLoadMaxBackups, "record-load-max-backups", 1, "number of rotated load traces to keep")
+	fs.StringVar(&f.NodeName, "node-name", "", "current node name")
+	fs.IntVar(&f.MetricsPort, "metrics-port", 8080, "port for prometheus metrics endpoint")
+}
+
+// ApplyFlags overrides c with the flags of fs that were set explicitly.
+func (c *Config) ApplyFlags(fs *flag.FlagSet, f StartupFlags) error {
+	var err error
+
+	if fs == nil {
+		return nil
+	}
+
+	fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "taint-threshold":
+			c.Thresholds.PSI.Taint = f.PressureTaintThreshold
+		case "evict-threshold":
+			c.Thresholds.PSI.Evict = f.PressureEvictThreshold
+		case "load-taint-threshold":
+			c.Thresholds.LoadAvg.Taint = f.LoadTaintThreshold
+		case "load-evict-threshold":
+			c.Thresholds.LoadAvg.Evict = f.LoadEvictThreshold
+		case "evict-backoff":
+			c.Eviction.Backoff.Duration = f.EvictBackoff
+		case "min-pod-age":
+			c.Eviction.MinPodAge.Duration = f.MinPodAge
+		case "selection-mode":
+			c.Eviction.SelectionMode = pressurecooker.SelectionMode(f.SelectionMode)
+		case "selection-fallback":
+			c.Eviction.SelectionFallback = f.SelectionFallback
+		case "evict-action":
+			c.Eviction.Action = pressurecooker.EvictAction(f.EvictAction)
+		case "resize-factor":
+			c.Eviction.Resize.Factor = f.ResizeFactor
+		case "resize-max-cpu":
+			q, qerr := resource.ParseQuantity(f.ResizeMaxCPU)
+			if qerr != nil {
+				err = qerr
+				return
+			}
+			c.Eviction.Resize.MaxCPU = q
+		case "dry-run":
+			c.DryRun = f.DryRun
+		case "dry-run-taint":
+			c.Taint.DryRun = f.DryRunTaint
+		case "dry-run-evict":
+			c.Eviction.DryRun = f.DryRunEvict
+		case "shutdown-taint":
+			c.Taint.OnShutdown = pressurecooker.ShutdownAction(f.ShutdownTaint)
+		case "shutdown-taint-expiry":
+			c.Taint.ShutdownExpiry.Duration = f.ShutdownTaintExpiry
+		case "cluster-evictions-per-minute":
+			c.Eviction.Budget.ClusterPerMinute = f.ClusterEvictionsPerMin
+		case "namespace-evictions-per-minute":
+			c.Eviction.Budget.NamespacePerMinute = f.NSEvictionsPerMin
+		case "budget-namespace":
+			c.Eviction.LeaseNamespace = f.BudgetNamespace
+		case "budget-lease":
+			c.Eviction.Budget.LeaseName = f.BudgetLeaseName
+		case "max-evictions-per-owner":
+			c.Eviction.Disruptions.MaxPerOwner = f.MaxEvictionsPerOwner
+		case "max-evictions-per-namespace":
+			c.Eviction.Disruptions.MaxPerNamespace = f.MaxEvictionsPerNS
+		case "disruption-window":
+			c.Eviction.Disruptions.Window.Duration = f.DisruptionWindow
+		case "disruption-lease":
+			c.Eviction.Disruptions.LeaseName = f.DisruptionLeaseName
+		}
+	})
+
+	return err
+}
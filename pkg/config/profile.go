@@ -0,0 +1,166 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Profile is a named set of overrides for the nodes matching NodeSelector.
+type Profile struct {
+	Name         string               `json:"name"`
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+	// any subset of the configuration, e.g. {"taint": {"enabled": false}}
+	Overrides json.RawMessage `json:"overrides"`
+}
+
+func (p *Profile) Matches(nodeLabels map[string]string) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&p.NodeSelector)
+	if err != nil {
+		return false, err
+	}
+
+	return selector.Matches(labels.Set(nodeLabels)), nil
+}
+
+// WithProfile returns c with the overrides of profile applied. The result
+// has no profiles of its own.
+func (c Config) WithProfile(p *Profile) (Config, error) {
+	resolved := c
+	resolved.Profiles = nil
+	resolved.Exclusions = copyExclusions(c)
+
+	if len(p.Overrides) == 0 {
+		return resolved, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(p.Overrides))
+	d.DisallowUnknownFields()
+	if err := d.Decode(&resolved); err != nil {
+		return Config{}, fmt.Errorf("profile %s: %s", p.Name, err.Error())
+	}
+
+	if len(resolved.Profiles) != 0 {
+		return Config{}, fmt.Errorf("profile %s: profiles can not be nested", p.Name)
+	}
+	if resolved.APIVersion != c.APIVersion || resolved.Kind != c.Kind {
+		return Config{}, fmt.Errorf("profile %s: apiVersion and kind can not be overridden", p.Name)
+	}
+
+	return resolved, nil
+}
+
+// ForNode returns the configuration for a node with the given labels and
+// the name of the selected profile, or "" if no profile matches.
+func (c Config) ForNode(nodeLabels map[string]string) (Config, string, error) {
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+
+		ok, err := p.Matches(nodeLabels)
+		if err != nil {
+			return Config{}, "", fmt.Errorf("profile %s: %s", p.Name, err.Error())
+		}
+		if !ok {
+			continue
+		}
+
+		resolved, err := c.WithProfile(p)
+		return resolved, p.Name, err
+	}
+
+	resolved := c
+	resolved.Profiles = nil
+	return resolved, "", nil
+}
+
+// copyExclusions copies the slices of c.Exclusions; decoding into a slice
+// reuses its backing array, which would otherwise modify c.
+func copyExclusions(c Config) (ex pressurecooker.Exclusions) {
+	ex = c.Exclusions
+	ex.Namespaces = append([]string(nil), c.Exclusions.Namespaces...)
+	ex.PriorityClasses = append([]string(nil), c.Exclusions.PriorityClasses...)
+	ex.OwnerKinds = append([]string(nil), c.Exclusions.OwnerKinds...)
+	return ex
+}
+
+// Overrides are the explicitly set flags, which take precedence over the
+// configuration file and its profiles.
+type Overrides struct {
+	flags *flag.FlagSet
+	f     StartupFlags
+}
+
+func NewOverrides(fs *flag.FlagSet, f StartupFlags) Overrides {
+	return Overrides{flags: fs, f: f}
+}
+
+// Changes lists the settings changed by the overrides.
+func (o Overrides) Changes() ([]string, error) {
+	c := Default()
+	if err := c.ApplyFlags(o.flags, o.f); err != nil {
+		return nil, err
+	}
+
+	return Diff(Default(), c), nil
+}
+
+// Resolve returns the validated configuration for a node with the given
+// labels and the name of the selected profile.
+func (c Config) Resolve(nodeLabels map[string]string, o Overrides) (Config, string, error) {
+	resolved, profile, err := c.ForNode(nodeLabels)
+	if err != nil {
+		return Config{}, "", err
+	}
+
+	if err := resolved.ApplyFlags(o.flags, o.f); err != nil {
+		return Config{}, "", err
+	}
+
+	if err := resolved.Validate(); err != nil {
+		if profile != "" {
+			return Config{}, "", fmt.Errorf("profile %s: %s", profile, err.Error())
+		}
+		return Config{}, "", err
+	}
+
+	return resolved, profile, nil
+}
+
+// ValidateAll validates c and each of its profiles with the overrides
+// applied, so that invalid profiles are reported before any node uses them.
+func (c Config) ValidateAll(o Overrides) error {
+	if err := validateProfiles(c.Profiles).ToAggregate(); err != nil {
+		return err
+	}
+
+	base := c
+	base.Profiles = nil
+	if err := base.ApplyFlags(o.flags, o.f); err != nil {
+		return err
+	}
+	if err := base.Validate(); err != nil {
+		return err
+	}
+
+	for i := range c.Profiles {
+		p := &c.Profiles[i]
+
+		resolved, err := c.WithProfile(p)
+		if err != nil {
+			return err
+		}
+		if err := resolved.ApplyFlags(o.flags, o.f); err != nil {
+			return err
+		}
+		if err := resolved.Validate(); err != nil {
+			return fmt.Errorf("profile %s: %s", p.Name, err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// Reloader polls a configuration file and sends every new, valid
+// configuration. Polling works with ConfigMap volumes, whose files are
+// replaced through symlink swaps.
+type Reloader struct {
+	Path     string
+	Interval time.Duration
+
+	overrides Overrides
+	last      []byte
+}
+
+// NewReloader creates a reloader for path. Updates are only sent if they
+// are valid with the overrides applied.
+func NewReloader(path string, o Overrides) *Reloader {
+	return &Reloader{
+		Path:      path,
+		Interval:  10 * time.Second,
+		overrides: o,
+	}
+}
+
+// Load reads, decodes and validates the file. The result still contains all
+// profiles, use Resolve to get the configuration for a node.
+func (r *Reloader) Load() (Config, error) {
+	data, err := ioutil.ReadFile(r.Path)
+	if err != nil {
+		return Config{}, err
+	}
+	r.last = data
+
+	return r.parse(data)
+}
+
+func (r *Reloader) parse(data []byte) (Config, error) {
+	c, err := Parse(data)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %s", r.Path, err.Error())
+	}
+	if err := c.ValidateAll(r.overrides); err != nil {
+		return Config{}, err
+	}
+
+	return c, nil
+}
+
+// Run polls the file until closeChan is closed. Invalid updates are logged
+// and skipped, so the receiver keeps its current configuration.
+func (r *Reloader) Run(closeChan chan struct{}) <-chan Config {
+	configs := make(chan Config)
+	ticker := time.NewTicker(r.Interval)
+
+	go func() {
+		defer ticker.Stop()
+		defer close(configs)
+
+		for {
+			select {
+			case <-ticker.C:
+				data, err := ioutil.ReadFile(r.Path)
+				if err != nil {
+					glog.Errorf("could not read configuration: %s", err.Error())
+					continue
+				}
+				if bytes.Equal(data, r.last) {
+					continue
+				}
+				// remember invalid content too, so it is only reported once
+				r.last = data
+
+				c, err := r.parse(data)
+				if err != nil {
+					glog.Errorf("rejecting configuration update, keeping the current configuration: %s", err.Error())
+					continue
+				}
+
+				select {
+				case configs <- c:
+				case <-closeChan:
+					return
+				}
+			case <-closeChan:
+				return
+			}
+		}
+	}()
+
+	return configs
+}
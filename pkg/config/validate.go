@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate reports all invalid fields at once.
+func (c *Config) Validate() error {
+	var errs field.ErrorList
+
+	errs = append(errs, validatePositiveDuration(c.Interval, field.NewPath("interval"))...)
+	errs = append(errs, validateThresholds(c.Thresholds.PSI, field.NewPath("thresholds", "psi"))...)
+	errs = append(errs, validateThresholds(c.Thresholds.LoadAvg, field.NewPath("thresholds", "loadavg"))...)
+	errs = append(errs, validateTaint(c.Taint, field.NewPath("taint"))...)
+	errs = append(errs, validateEviction(c.Eviction, field.NewPath("eviction"))...)
+	errs = append(errs, validateScoring(c.Scoring, field.NewPath("scoring"))...)
+	errs = append(errs, validateExclusions(c.Exclusions, field.NewPath("exclusions"))...)
+
+	return errs.ToAggregate()
+}
+
+func validatePositiveDuration(d metav1.Duration, p *field.Path) field.ErrorList {
+	if d.Duration <= 0 {
+		return field.ErrorList{field.Invalid(p, d.Duration.String(), "must be positive")}
+	}
+	return nil
+}
+
+func validateNonNegativeDuration(d metav1.Duration, p *field.Path) field.ErrorList {
+	if d.Duration < 0 {
+		return field.ErrorList{field.Invalid(p, d.Duration.String(), "must not be negative")}
+	}
+	return nil
+}
+
+func validateThresholds(t ThresholdPair, p *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if t.Taint <= 0 {
+		errs = append(errs, field.Invalid(p.Child("taint"), t.Taint, "must be positive"))
+	}
+	if t.Evict < t.Taint {
+		errs = append(errs, field.Invalid(p.Child("evict"), t.Evict, "must not be lower than the taint threshold"))
+	}
+
+	return errs
+}
+
+func validateTaint(t TaintConfig, p *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for _, msg := range validation.IsQualifiedName(t.Key) {
+		errs = append(errs, field.Invalid(p.Child("key"), t.Key, msg))
+	}
+	if t.Value != "" {
+		for _, msg := range validation.IsValidLabelValue(t.Value) {
+			errs = append(errs, field.Invalid(p.Child("value"), t.Value, msg))
+		}
+	}
+
+	// NoExecute would evict every pod without a toleration at once
+	switch t.Effect {
+	case v1.TaintEffectPreferNoSchedule, v1.TaintEffectNoSchedule:
+	default:
+		errs = append(errs, field.NotSupported(p.Child("effect"), t.Effect, []string{
+			string(v1.TaintEffectPreferNoSchedule),
+			string(v1.TaintEffectNoSchedule),
+		}))
+	}
+
+	if _, err := pressurecooker.ParseShutdownAction(string(t.OnShutdown)); err != nil {
+		errs = append(errs, field.NotSupported(p.Child("onShutdown"), t.OnShutdown, []string{
+			string(pressurecooker.ShutdownActionRemove),
+			string(pressurecooker.ShutdownActionExpire),
+			string(pressurecooker.ShutdownActionKeep),
+		}))
+	}
+	errs = append(errs, validatePositiveDuration(t.ShutdownExpiry, p.Child("shutdownExpiry"))...)
+
+	return errs
+}
+
+func validateEviction(e EvictionConfig, p *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	if _, err := pressurecooker.ParseEvictAction(string(e.Action)); err != nil {
+		errs = append(errs, field.NotSupported(p.Child("action"), e.Action, []string{
+			string(pressurecooker.EvictActionEvict),
+			string(pressurecooker.EvictActionResize),
+		}))
+	}
+	if _, err := pressurecooker.ParseSelectionMode(string(e.SelectionMode)); err != nil {
+		errs = append(errs, field.NotSupported(p.Child("selectionMode"), e.SelectionMode, []string{
+			string(pressurecooker.SelectionModeAge),
+			string(pressurecooker.SelectionModeCPUUsage),
+		}))
+	}
+
+	errs = append(errs, validateNonNegativeDuration(e.Backoff, p.Child("backoff"))...)
+	errs = append(errs, validateNonNegativeDuration(e.MinPodAge, p.Child("minPodAge"))...)
+
+	for _, msg := range validation.IsDNS1123Label(e.LeaseNamespace) {
+		errs = append(errs, field.Invalid(p.Child("leaseNamespace"), e.LeaseNamespace, msg))
+	}
+
+	if e.Resize.Factor <= 1 {
+		errs = append(errs, field.Invalid(p.Child("resize", "factor"), e.Resize.Factor, "must be greater than 1"))
+	}
+	if e.Resize.MaxCPU.Sign() <= 0 {
+		errs = append(errs, field.Invalid(p.Child("resize", "maxCPU"), e.Resize.MaxCPU.String(), "must be positive"))
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(e.Budget.LeaseName) {
+		errs = append(errs, field.Invalid(p.Child("budget", "leaseName"), e.Budget.LeaseName, msg))
+	}
+	if e.Budget.ClusterPerMinute < 0 {
+		errs = append(errs, field.Invalid(p.Child("budget", "clusterPerMinute"), e.Budget.ClusterPerMinute, "must not be negative"))
+	}
+	if e.Budget.NamespacePerMinute < 0 {
+		errs = append(errs, field.Invalid(p.Child("budget", "namespacePerMinute"), e.Budget.NamespacePerMinute, "must not be negative"))
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(e.Disruptions.LeaseName) {
+		errs = append(errs, field.Invalid(p.Child("disruptions", "leaseName"), e.Disruptions.LeaseName, msg))
+	}
+	errs = append(errs, validatePositiveDuration(e.Disruptions.Window, p.Child("disruptions", "window"))...)
+	if e.Disruptions.MaxPerOwner < 0 {
+		errs = append(errs, field.Invalid(p.Child("disruptions", "maxPerOwner"), e.Disruptions.MaxPerOwner, "must not be negative"))
+	}
+	if e.Disruptions.MaxPerNamespace < 0 {
+		errs = append(errs, field.Invalid(p.Child("disruptions", "maxPerNamespace"), e.Disruptions.MaxPerNamespace, "must not be negative"))
+	}
+
+	return errs
+}
+
+// maxScoringWeight keeps the sum of all rules far away from an int overflow
+const maxScoringWeight = 10000
+
+func validateScoring(w pressurecooker.ScoringWeights, p *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for _, weight := range []struct {
+		name  string
+		value int
+	}{
+		{"bestEffort", w.BestEffort},
+		{"burstable", w.Burstable},
+		{"guaranteed", w.Guaranteed},
+		{"replicaSet", w.ReplicaSet},
+		{"age", w.Age},
+		{"cpuUsage", w.CPUUsage},
+	} {
+		if weight.value < 0 || weight.value > maxScoringWeight {
+			errs = append(errs, field.Invalid(p.Child(weight.name), weight.value, fmt.Sprintf("must be between 0 and %d", maxScoringWeight)))
+		}
+	}
+
+	return errs
+}
+
+func validateExclusions(ex pressurecooker.Exclusions, p *field.Path) field.ErrorList {
+	var errs field.ErrorList
+
+	for i, ns := range ex.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			errs = append(errs, field.Invalid(p.Child("namespaces").Index(i), ns, msg))
+		}
+	}
+	for i, pc := range ex.PriorityClasses {
+		if pc == "" {
+			errs = append(errs, field.Required(p.Child("priorityClasses").Index(i), ""))
+		}
+	}
+	for i, kind := range ex.OwnerKinds {
+		if kind == "" {
+			errs = append(errs, field.Required(p.Child("ownerKinds").Index(i), ""))
+		}
+	}
+
+	return errs
+}
+
+func validateProfiles(profiles []Profile) field.ErrorList {
+	var errs field.ErrorList
+
+	p := field.NewPath("profiles")
+	names := make(map[string]bool)
+	for i := range profiles {
+		if profiles[i].Name == "" {
+			errs = append(errs, field.Required(p.Index(i).Child("name"), ""))
+		} else if names[profiles[i].Name] {
+			errs = append(errs, field.Duplicate(p.Index(i).Child("name"), profiles[i].Name))
+		}
+		names[profiles[i].Name] = true
+
+		if _, err := metav1.LabelSelectorAsSelector(&profiles[i].NodeSelector); err != nil {
+			errs = append(errs, field.Invalid(p.Index(i).Child("nodeSelector"), profiles[i].NodeSelector, err.Error()))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateScoring(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*Config)
+		err       string
+	}{
+		{
+			name:      "defaults",
+			configure: func(c *Config) {},
+		},
+		{
+			name:      "zero weight",
+			configure: func(c *Config) { c.Scoring.Guaranteed = 0 },
+		},
+		{
+			name:      "maximum weight",
+			configure: func(c *Config) { c.Scoring.CPUUsage = maxScoringWeight },
+		},
+		{
+			name:      "negative weight",
+			configure: func(c *Config) { c.Scoring.BestEffort = -1 },
+			err:       "scoring.bestEffort",
+		},
+		{
+			name:      "weight above the maximum",
+			configure: func(c *Config) { c.Scoring.Age = maxScoringWeight + 1 },
+			err:       "scoring.age",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := Default()
+			test.configure(&c)
+
+			err := c.Validate()
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %s", err.Error())
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("expected an error for %s, got %v", test.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"k8s.io/client-go/kubernetes"
+)
+
+// configTarget holds everything a configuration is applied to. Reloads are
+// applied from the main loop, so the evicter and tainter are never in use
+// at the same time.
+type configTarget struct {
+	client   kubernetes.Interface
+	nodeName string
+	usePSI   bool
+
+	watcher *pressurecooker.Watcher
+	tainter *pressurecooker.Tainter
+	evicter *pressurecooker.Evicter
+}
+
+// apply configures the watcher, evicter and tainter from conf. It is used
+// at startup and for reloads. Enabling and disabling actions is left to
+// applySwitches.
+func (ct *configTarget) apply(conf config.Config) error {
+	w, t, e := ct.watcher, ct.tainter, ct.evicter
+
+	var ug pressurecooker.PodUsageGetter
+	if conf.Eviction.SelectionMode == pressurecooker.SelectionModeCPUUsage {
+		ug = &pressurecooker.KubeletSummaryUsageGetter{Client: ct.client, NodeName: ct.nodeName}
+	}
+	if err := e.SetSelectionMode(conf.Eviction.SelectionMode, ug, conf.Eviction.SelectionFallback); err != nil {
+		return err
+	}
+
+	if err := e.SetAction(conf.Eviction.Action, conf.Eviction.Resize.Factor, conf.Eviction.Resize.MaxCPU); err != nil {
+		return err
+	}
+
+	pressureThreshold.WithLabelValues("taint", "psi").Set(conf.Thresholds.PSI.Taint)
+	pressureThreshold.WithLabelValues("evict", "psi").Set(conf.Thresholds.PSI.Evict)
+	pressureThreshold.WithLabelValues("taint", "loadavg").Set(conf.Thresholds.LoadAvg.Taint)
+	pressureThreshold.WithLabelValues("evict", "loadavg").Set(conf.Thresholds.LoadAvg.Evict)
+
+	thresholds := conf.Thresholds.LoadAvg
+	if ct.usePSI {
+		thresholds = conf.Thresholds.PSI
+	}
+	w.SetThreshold(thresholds.Taint)
+	e.SetThreshold(thresholds.Evict)
+
+	e.SetTiming(conf.Eviction.Backoff.Duration, conf.Eviction.MinPodAge.Duration)
+	e.SetScoring(conf.Scoring, conf.Exclusions)
+
+	budget := conf.Eviction.Budget
+	if budget.ClusterPerMinute > 0 || budget.NamespacePerMinute > 0 {
+		e.SetEvictionBudget(pressurecooker.NewEvictionBudget(ct.client, conf.Eviction.LeaseNamespace, budget.LeaseName, ct.nodeName, budget.ClusterPerMinute, budget.NamespacePerMinute))
+	} else {
+		e.SetEvictionBudget(nil)
+	}
+
+	disruptions := conf.Eviction.Disruptions
+	if disruptions.MaxPerOwner > 0 || disruptions.MaxPerNamespace > 0 {
+		e.SetDisruptionLimiter(pressurecooker.NewDisruptionLimiter(ct.client, conf.Eviction.LeaseNamespace, disruptions.LeaseName, ct.nodeName, disruptions.MaxPerOwner, disruptions.MaxPerNamespace, disruptions.Window.Duration))
+	} else {
+		e.SetDisruptionLimiter(nil)
+	}
+
+	dryRunTaint := conf.DryRun || conf.Taint.DryRun
+	dryRunEvict := conf.DryRun || conf.Eviction.DryRun
+	t.SetDryRun(dryRunTaint)
+	e.SetDryRun(dryRunEvict)
+	setDryRunMetric("taint", dryRunTaint)
+	setDryRunMetric("evict", dryRunEvict)
+
+	return nil
+}
+
+// reload applies next on top of current and returns the configuration that
+// is in effect afterwards. Settings that can not be changed at runtime keep
+// their current values.
+func (ct *configTarget) reload(current, next config.Config) config.Config {
+	if next.Interval != current.Interval {
+		glog.Warningf("changing the interval requires a restart, keeping %s", current.Interval.Duration)
+		next.Interval = current.Interval
+	}
+	if taint := current.Taint.ToTaint(); next.Taint.ToTaint() != taint {
+		glog.Warningf("changing the taint requires a restart, keeping %s", taint.ToString())
+		next.Taint.Key = current.Taint.Key
+		next.Taint.Value = current.Taint.Value
+		next.Taint.Effect = current.Taint.Effect
+	}
+
+	changes := config.Diff(current, next)
+	if len(changes) == 0 {
+		return current
+	}
+
+	if err := ct.apply(next); err != nil {
+		glog.Errorf("rejecting configuration update, keeping the current configuration: %s", err.Error())
+		// restore everything apply might have changed before failing
+		if err := ct.apply(current); err != nil {
+			glog.Errorf("could not restore the current configuration: %s", err.Error())
+		}
+		return current
+	}
+
+	for _, change := range changes {
+		glog.Infof("configuration changed: %s", change)
+	}
+
+	return next
+}
@@ -0,0 +1,435 @@
+// Package controller ties the watcher, tainter and evicter together into the
+// control loop of one node.
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/policy"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options are the settings of a Controller that are fixed at startup.
+type Options struct {
+	NodeName string
+	// use the PSI thresholds instead of the loadavg thresholds
+	UsePSI bool
+
+	Base      config.Config
+	Overrides config.Overrides
+	// optional, the status of the adopted PressurePolicy is updated when
+	// the profile changes
+	Policies *policy.Watcher
+
+	KillSwitchNamespace string
+	KillSwitchName      string
+
+	// optional
+	Notifier pressurecooker.Notifier
+	AuditLog *pressurecooker.AuditLog
+}
+
+// Controller taints the node while the load is above the taint threshold
+// and evicts pods while it stays above the evict threshold. The state below
+// is owned by the goroutine calling Run.
+type Controller struct {
+	clock     clock.Clock
+	overrides config.Overrides
+	policies  *policy.Watcher
+
+	recorder *pressurecooker.Recorder
+	audit    *pressurecooker.AuditLog
+	watcher  *pressurecooker.Watcher
+	tainter  *pressurecooker.Tainter
+	evicter  *pressurecooker.Evicter
+	switches *pressurecooker.Switches
+	target   *configTarget
+	status   *statusServer
+
+	base        config.Config
+	conf        config.Config
+	profile     string
+	nodeLabels  map[string]string
+	switchState pressurecooker.SwitchState
+	enabled     pressurecooker.SwitchState
+	// the load exceeded the taint threshold and did not recover yet
+	isHigh bool
+	// the node carries the taint, which is not the case for a high load
+	// while tainting is disabled or in dry-run mode
+	isTainted bool
+
+	lastDisabledCheck time.Time
+}
+
+// NewController resolves the configuration for the node and sets up the
+// watcher, tainter and evicter. It does not change the node; that is left
+// to Start.
+func NewController(c kubernetes.Interface, lg pressurecooker.LoadGetter, clk clock.Clock, o Options) (*Controller, error) {
+	// shared by the tainter and evicter, so their Events carry the same incident ID
+	rec, err := pressurecooker.NewRecorder(c, o.NodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := pressurecooker.NewTainter(c, rec, o.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	t.SetClock(clk)
+
+	nodeLabels, err := t.NodeLabels()
+	if err != nil {
+		return nil, err
+	}
+
+	conf, profile, err := o.Base.Resolve(nodeLabels, o.Overrides)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %s", err.Error())
+	}
+	if profile != "" {
+		glog.Infof("using configuration profile %s", profile)
+	}
+	if o.Policies != nil {
+		adopt(o.Policies, conf, profile)
+	}
+
+	// thresholds and everything else that can be reloaded are set by apply
+	w, err := pressurecooker.NewWatcher(0, lg)
+	if err != nil {
+		return nil, err
+	}
+	w.TickerInterval = conf.Interval.Duration
+	w.SetClock(clk)
+
+	t.SetTaint(conf.Taint.ToTaint())
+
+	e, err := pressurecooker.NewEvicter(c, rec, 0, o.NodeName, conf.Eviction.Backoff.Duration, conf.Eviction.MinPodAge.Duration)
+	if err != nil {
+		return nil, err
+	}
+	e.SetClock(clk)
+
+	o.AuditLog.SetClock(clk)
+	w.SetAuditLog(o.AuditLog)
+	t.SetAuditLog(o.AuditLog)
+	e.SetAuditLog(o.AuditLog)
+
+	if o.Notifier != nil {
+		t.SetNotifier(o.Notifier)
+		e.SetNotifier(o.Notifier)
+	}
+
+	target := &configTarget{
+		client:   c,
+		nodeName: o.NodeName,
+		usePSI:   o.UsePSI,
+		watcher:  w,
+		tainter:  t,
+		evicter:  e,
+	}
+	if err := target.apply(conf); err != nil {
+		return nil, err
+	}
+
+	switches := pressurecooker.NewSwitches(c, o.NodeName, o.KillSwitchNamespace, o.KillSwitchName)
+	switches.SetClock(clk)
+
+	return &Controller{
+		clock:      clk,
+		overrides:  o.Overrides,
+		policies:   o.Policies,
+		recorder:   rec,
+		audit:      o.AuditLog,
+		watcher:    w,
+		tainter:    t,
+		evicter:    e,
+		switches:   switches,
+		target:     target,
+		status:     &statusServer{nodeName: o.NodeName, watcher: w, clock: clk},
+		base:       o.Base,
+		conf:       conf,
+		profile:    profile,
+		nodeLabels: nodeLabels,
+	}, nil
+}
+
+// Start takes over the taint of a previous instance and checks the disable
+// switches. It must be called before Run.
+func (c *Controller) Start() error {
+	if err := c.tainter.ReconcileStaleTaint(); err != nil {
+		glog.Errorf("could not reconcile taint left behind by a previous instance: %s", err.Error())
+	}
+
+	isTainted, err := c.tainter.IsNodeTainted()
+	if err != nil {
+		return err
+	}
+	// a taint left behind means the load was high before the restart
+	c.isHigh = isTainted
+	c.setTainted(isTainted)
+
+	switchState, err := c.switches.Check()
+	if err != nil {
+		return err
+	}
+	c.switchState = switchState
+	c.lastDisabledCheck = c.clock.Now()
+	c.applySwitches()
+
+	c.watcher.SetAsHigh(isTainted)
+	if isTainted {
+		pressureThresholdExceeded.Set(1)
+	} else {
+		pressureThresholdExceeded.Set(0)
+	}
+
+	return nil
+}
+
+// Run samples the load and acts on it until closeChan is closed. Base
+// configurations received on reloads replace the current one.
+func (c *Controller) Run(closeChan chan struct{}, reloads <-chan config.Config) {
+	// profiles are re-evaluated when the node's labels change
+	labelTicker := c.clock.NewTicker(1 * time.Minute)
+	defer labelTicker.Stop()
+
+	exc, dec, errs := c.watcher.Run(closeChan)
+	for {
+		c.updateStatus()
+
+		select {
+		case next, ok := <-reloads:
+			if !ok {
+				reloads = nil
+				continue
+			}
+
+			c.base = next
+			c.resolve()
+		case <-labelTicker.C():
+			c.refreshLabels()
+		case evt, ok := <-exc:
+			if !ok {
+				glog.Infof("exceedance channel closed; stopping")
+				return
+			}
+
+			c.exceeded(evt)
+		case evt, ok := <-dec:
+			if !ok {
+				glog.Infof("deceedance channel closed; stopping")
+				return
+			}
+
+			c.deceeded(evt)
+		case err, ok := <-errs:
+			if !ok {
+				glog.Infof("error channel closed; stopping")
+				return
+			}
+
+			glog.Errorf("error while polling for status updates: %s", err.Error())
+		}
+	}
+}
+
+// Sample reads the load once and acts on it like Run, but synchronously. It
+// is used to drive the controller with a fake clock. Errors reading the load
+// are returned instead of logged.
+func (c *Controller) Sample() error {
+	evt, typ, err := c.watcher.Sample()
+	if err != nil {
+		return err
+	}
+
+	switch typ {
+	case pressurecooker.ThresholdExceeded:
+		c.exceeded(evt)
+	case pressurecooker.ThresholdDeceeded:
+		c.deceeded(evt)
+	}
+	c.updateStatus()
+
+	return nil
+}
+
+// Watcher returns the watcher sampling the load.
+func (c *Controller) Watcher() *pressurecooker.Watcher {
+	return c.watcher
+}
+
+// Evicter returns the evicter, e.g. to explain its ranking.
+func (c *Controller) Evicter() *pressurecooker.Evicter {
+	return c.evicter
+}
+
+// Status returns the handler for /status.
+func (c *Controller) Status() http.Handler {
+	return c.status
+}
+
+// High reports whether the load exceeded the taint threshold and did not
+// recover yet. Like Tainted, it must not be called while Run is active.
+func (c *Controller) High() bool {
+	return c.isHigh
+}
+
+// Tainted reports whether the node carries the taint.
+func (c *Controller) Tainted() bool {
+	return c.isTainted
+}
+
+func (c *Controller) updateStatus() {
+	c.status.update(c.isTainted, c.profile, c.enabled, c.evicter)
+}
+
+// Shutdown hands over the taint as configured. It must be called after Run
+// returned.
+func (c *Controller) Shutdown() error {
+	return c.tainter.Shutdown(c.conf.Taint.OnShutdown, c.conf.Taint.ShutdownExpiry.Duration)
+}
+
+func (c *Controller) refreshLabels() {
+	labels, err := c.tainter.NodeLabels()
+	if err != nil {
+		glog.Errorf("could not get node labels: %s", err.Error())
+		return
+	}
+	c.nodeLabels = labels
+
+	c.resolve()
+}
+
+// resolve applies the configuration for the current base and node labels.
+func (c *Controller) resolve() {
+	c.conf, c.profile = resolveConfig(c.base, c.nodeLabels, c.overrides, c.target, c.conf, c.profile, c.policies)
+	c.applySwitches()
+}
+
+func (c *Controller) exceeded(evt pressurecooker.ThresholdEvent) {
+	t, e := c.tainter, c.evicter
+
+	if c.clock.Since(c.lastDisabledCheck) > 1*time.Minute {
+		if state, err := c.switches.Check(); err == nil {
+			c.switchState = state
+			c.applySwitches()
+		} else {
+			glog.Errorf("could not check disable switches: %s", err.Error())
+		}
+		c.lastDisabledCheck = c.clock.Now()
+	}
+	isDisabled := c.enabled.AllDisabled()
+	if isDisabled && c.isTainted {
+		c.untaint(pressurecooker.ThresholdEvent{})
+	}
+
+	if isDisabled {
+		glog.Infof("pressurecooker disabled, pressure: %v", evt.String())
+		return
+	}
+
+	if c.isHigh {
+		// the taint may have been removed by a disable switch that is no
+		// longer active; a dry run never adds it, so it is not retried
+		dryRun := c.conf.DryRun || c.conf.Taint.DryRun
+		if !c.isTainted && c.enabled.Taint == "" && !dryRun {
+			glog.Infof("pressure still high, tainting node again")
+			if tainted, err := t.TaintNode(evt); err != nil {
+				glog.Errorf("error while tainting node: %s", err.Error())
+			} else {
+				c.setTainted(tainted)
+			}
+		}
+
+		if _, err := e.EvictPod(evt); err != nil {
+			glog.Errorf("error while evicting pod: %s", err.Error())
+		}
+		return
+	}
+
+	glog.Infof("5 minute pressure average exceeded threshold, %v", evt.Load)
+
+	tainted, err := t.TaintNode(evt)
+	if err != nil {
+		glog.Errorf("error while tainting node: %s", err.Error())
+		return
+	}
+
+	c.isHigh = true
+	c.setTainted(tainted)
+	pressureThresholdExceeded.Set(1)
+	pressureThresholdExceededTotal.Inc()
+}
+
+func (c *Controller) deceeded(evt pressurecooker.ThresholdEvent) {
+	if !c.isHigh {
+		// the taint could not be added, but the incident ends anyway
+		c.recorder.EndIncident()
+		return
+	}
+
+	glog.Infof("pressure deceeded threshold, %s", evt.String())
+	// also called without a taint, to report a dry-run untaint and end the
+	// incident
+	if !c.untaint(evt) {
+		return
+	}
+
+	c.isHigh = false
+	pressureThresholdExceeded.Set(0)
+	pressureRecoveredTotal.Inc()
+}
+
+// untaint removes the taint and updates the state and metrics accordingly.
+// It reports whether the taint is gone. The load stays high until deceeded.
+func (c *Controller) untaint(evt pressurecooker.ThresholdEvent) bool {
+	if err := c.tainter.UntaintNode(evt); err != nil {
+		glog.Errorf("error while removing taint from node: %s", err.Error())
+		return false
+	}
+
+	c.setTainted(false)
+	return true
+}
+
+func (c *Controller) setTainted(tainted bool) {
+	c.isTainted = tainted
+	nodeTainted.Set(boolToFloat(tainted))
+}
+
+// resolveConfig selects the profile for the node and applies the result.
+// It returns the configuration and profile in effect afterwards.
+func resolveConfig(base config.Config, nodeLabels map[string]string, overrides config.Overrides, target *configTarget, current config.Config, currentProfile string, policies *policy.Watcher) (config.Config, string) {
+	next, profile, err := base.Resolve(nodeLabels, overrides)
+	if err != nil {
+		glog.Errorf("rejecting configuration, keeping the current configuration: %s", err.Error())
+		return current, currentProfile
+	}
+
+	if profile != currentProfile {
+		glog.Infof("switching configuration profile from %q to %q", currentProfile, profile)
+		if policies != nil {
+			adopt(policies, next, profile)
+		}
+	}
+
+	return target.reload(current, next), profile
+}
+
+// adopt records the policy in use in the policy status, unless in dry run.
+func adopt(policies *policy.Watcher, conf config.Config, profile string) {
+	if conf.DryRun {
+		glog.Infof("[dry-run] adopting pressure policy %q", profile)
+		return
+	}
+
+	if err := policies.Adopt(profile); err != nil {
+		glog.Errorf("could not update pressure policy status: %s", err.Error())
+	}
+}
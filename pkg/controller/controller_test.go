@@ -0,0 +1,538 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/controller/controllertest"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestController(t *testing.T) {
+	tests := []struct {
+		name      string
+		configure func(*config.Config)
+		node      func(*v1.Node)
+		pods      []runtime.Object
+		loads     []float64
+		tainted   bool
+		evicted   []string
+	}{
+		{
+			name:  "low load",
+			pods:  []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads: controllertest.Repeat(10, 10),
+		},
+		{
+			name: "pressure 30 for 5 ticks then 60 taints then evicts the oldest pod",
+			pods: []runtime.Object{
+				controllertest.NewPod("default", "young", time.Hour),
+				controllertest.NewPod("default", "old", 48*time.Hour),
+			},
+			loads:   controllertest.Concat(controllertest.Repeat(30, 5), controllertest.Repeat(60, 1)),
+			tainted: true,
+			evicted: []string{"default/old"},
+		},
+		{
+			name:    "taint without eviction below the evict threshold",
+			pods:    []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads:   controllertest.Repeat(40, 10),
+			tainted: true,
+		},
+		{
+			name:    "taint removed after recovery",
+			pods:    []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads:   controllertest.Concat(controllertest.Repeat(30, 5), controllertest.Repeat(10, 1)),
+			tainted: false,
+		},
+		{
+			name:    "taint kept between the thresholds",
+			loads:   controllertest.Concat(controllertest.Repeat(30, 1), []float64{20, 24, 26}),
+			tainted: true,
+		},
+		{
+			name: "one eviction per backoff",
+			pods: []runtime.Object{
+				controllertest.NewPod("default", "a", time.Hour),
+				controllertest.NewPod("default", "b", 48*time.Hour),
+			},
+			loads:   controllertest.Repeat(60, 5),
+			tainted: true,
+			evicted: []string{"default/b"},
+		},
+		{
+			name: "pods younger than the min pod age are kept",
+			pods: []runtime.Object{
+				controllertest.NewPod("default", "new", time.Minute),
+			},
+			loads:   controllertest.Repeat(60, 3),
+			tainted: true,
+		},
+		{
+			name: "kube-system is excluded",
+			pods: []runtime.Object{
+				controllertest.NewPod("kube-system", "dns", 48*time.Hour),
+				controllertest.NewPod("default", "a", time.Hour),
+			},
+			loads:   controllertest.Repeat(60, 2),
+			tainted: true,
+			evicted: []string{"default/a"},
+		},
+		{
+			name:      "dry-run changes nothing",
+			configure: func(c *config.Config) { c.DryRun = true },
+			pods:      []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads:     controllertest.Repeat(60, 3),
+		},
+		{
+			name:      "eviction disabled by configuration",
+			configure: func(c *config.Config) { c.Eviction.Enabled = false },
+			pods:      []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads:     controllertest.Repeat(60, 3),
+			tainted:   true,
+		},
+		{
+			name:      "tainting disabled by configuration",
+			configure: func(c *config.Config) { c.Taint.Enabled = false },
+			pods:      []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads:     controllertest.Repeat(60, 3),
+			evicted:   []string{"default/a"},
+		},
+		{
+			name:  "disabled by node label",
+			node:  func(n *v1.Node) { n.Labels[pressurecooker.EnabledLabel] = "false" },
+			pods:  []runtime.Object{controllertest.NewPod("default", "a", time.Hour)},
+			loads: controllertest.Repeat(60, 3),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config.Default()
+			if tt.configure != nil {
+				tt.configure(&conf)
+			}
+			node := controllertest.NewNode(controllertest.NodeName)
+			if tt.node != nil {
+				tt.node(node)
+			}
+
+			h := controllertest.New(t, conf, node, tt.pods...)
+			h.Run(tt.loads)
+
+			if tainted := h.Tainted(); tainted != tt.tainted {
+				t.Errorf("tainted = %t, want %t", tainted, tt.tainted)
+			}
+			if evicted := h.Evicted(); !reflect.DeepEqual(evicted, tt.evicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.evicted)
+			}
+		})
+	}
+}
+
+// TestControllerHighWithoutTaint checks that a high load is not reported as
+// a taint while tainting is disabled.
+func TestControllerHighWithoutTaint(t *testing.T) {
+	conf := config.Default()
+	conf.Taint.Enabled = false
+	h := controllertest.New(t, conf, nil)
+
+	h.Run(controllertest.Repeat(30, 1))
+	if !h.Controller.High() || h.Controller.Tainted() {
+		t.Fatalf("isHigh = %t, isTainted = %t while tainting is disabled, want true, false", h.Controller.High(), h.Controller.Tainted())
+	}
+
+	h.Run(controllertest.Repeat(10, 1))
+	if h.Controller.High() {
+		t.Errorf("isHigh = true after recovery, want false")
+	}
+}
+
+func TestControllerDisableRemovesTaint(t *testing.T) {
+	tests := []struct {
+		name    string
+		disable func(*v1.Node)
+		enable  func(*v1.Node)
+	}{
+		{
+			name:    "node label",
+			disable: func(n *v1.Node) { n.Labels[pressurecooker.EnabledLabel] = "false" },
+			enable:  func(n *v1.Node) { delete(n.Labels, pressurecooker.EnabledLabel) },
+		},
+		{
+			name: "taint annotation",
+			disable: func(n *v1.Node) {
+				n.Annotations = map[string]string{
+					pressurecooker.TaintDisabledUntilAnnotation: controllertest.Start.Add(time.Hour).Format(time.RFC3339),
+				}
+			},
+			enable: func(n *v1.Node) { delete(n.Annotations, pressurecooker.TaintDisabledUntilAnnotation) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := controllertest.New(t, config.Default(), nil)
+			update := func(change func(*v1.Node)) {
+				node := h.Node()
+				change(node)
+				if _, err := h.Client.CoreV1().Nodes().Update(node); err != nil {
+					t.Fatalf("could not update node: %s", err.Error())
+				}
+			}
+
+			h.Run(controllertest.Repeat(30, 1))
+			if !h.Tainted() || !h.Controller.Tainted() {
+				t.Fatalf("node not tainted after the load exceeded the threshold")
+			}
+
+			// the switches are checked once a minute
+			update(tt.disable)
+			h.Wait(time.Minute)
+			h.Tick(30)
+			if h.Tainted() || h.Controller.Tainted() {
+				t.Errorf("taint kept after tainting was disabled")
+			}
+			if !h.Controller.High() {
+				t.Errorf("High() = false while the load is still high")
+			}
+
+			update(tt.enable)
+			h.Wait(time.Minute)
+			h.Tick(30)
+			if !h.Tainted() || !h.Controller.Tainted() {
+				t.Errorf("taint not added again after tainting was enabled under high load")
+			}
+		})
+	}
+}
+
+func TestControllerEvictionBackoff(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil,
+		controllertest.NewPod("default", "a", time.Hour),
+		controllertest.NewPod("default", "b", 48*time.Hour),
+	)
+
+	// taint, evict b, then back off for 10 minutes
+	h.Run(controllertest.Repeat(60, 2))
+	h.Wait(9 * time.Minute)
+	h.Tick(60)
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/b"}) {
+		t.Fatalf("evicted = %v within the backoff, want [default/b]", evicted)
+	}
+
+	h.Wait(time.Minute)
+	h.Tick(60)
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/b", "default/a"}) {
+		t.Errorf("evicted = %v after the backoff, want [default/b default/a]", evicted)
+	}
+}
+
+func TestControllerFailedEvictionKeepsLimits(t *testing.T) {
+	conf := config.Default()
+	conf.Eviction.Backoff.Duration = time.Minute
+	// one eviction every 10 minutes, one per owner per hour
+	conf.Eviction.Budget.ClusterPerMinute = 0.1
+	conf.Eviction.Disruptions.MaxPerOwner = 1
+
+	h := controllertest.New(t, conf, nil, controllertest.NewPod("default", "a", time.Hour))
+	failed := false
+	h.Client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" || failed {
+			return false, nil, nil
+		}
+		failed = true
+		return true, nil, apierrors.NewTooManyRequests("disruption budget", 10)
+	})
+
+	// the first eviction fails, the second one after the backoff must not be
+	// blocked by the budget or disruption history of the first
+	h.Run(controllertest.Repeat(60, 2))
+	h.Wait(time.Minute)
+	h.Tick(60)
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/a", "default/a"}) {
+		t.Errorf("evicted = %v, want a failed and a successful eviction of default/a", evicted)
+	}
+	if _, err := h.Client.CoreV1().Pods("default").Get("a", metav1.GetOptions{}); err == nil {
+		t.Errorf("default/a was not evicted")
+	}
+}
+
+func TestControllerRejectedEvictionNotRecorded(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil, controllertest.NewPod("default", "a", time.Hour))
+	h.Client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		return true, nil, apierrors.NewTooManyRequests("disruption budget", 10)
+	})
+
+	// taint, then two rejected evictions without a backoff in between
+	h.Run(controllertest.Repeat(60, 3))
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/a", "default/a"}) {
+		t.Errorf("evicted = %v, want two attempts for default/a", evicted)
+	}
+	if history := h.Controller.Evicter().History(); len(history) != 0 {
+		t.Errorf("history = %v, want no rejected evictions", history)
+	}
+	if _, ok := h.Node().Annotations[pressurecooker.EvictionStateAnnotation]; ok {
+		t.Errorf("rejected eviction was persisted on the node")
+	}
+}
+
+func TestControllerResizeFallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		evicted []string
+	}{
+		{
+			name:    "resize not supported",
+			err:     apierrors.NewNotFound(schema.GroupResource{Resource: "pods/resize"}, "a"),
+			evicted: []string{"default/a"},
+		},
+		{
+			name: "resize timed out",
+			err:  apierrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "patch", 1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config.Default()
+			conf.Eviction.Action = pressurecooker.EvictActionResize
+
+			pod := controllertest.NewPod("default", "a", time.Hour)
+			pod.Spec.Containers = []v1.Container{{
+				Name: "app",
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{v1.ResourceCPU: resource.MustParse("100m")},
+				},
+			}}
+
+			h := controllertest.New(t, conf, nil, pod)
+			h.Client.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return action.GetSubresource() == "resize", nil, tt.err
+			})
+
+			h.Run(controllertest.Repeat(60, 2))
+			if evicted := h.Evicted(); !reflect.DeepEqual(evicted, tt.evicted) {
+				t.Errorf("evicted = %v, want %v", evicted, tt.evicted)
+			}
+		})
+	}
+}
+
+func TestControllerMinPodAge(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil, controllertest.NewPod("default", "new", time.Minute))
+
+	// the pod is 5 minutes old on the 16th tick
+	h.Run(controllertest.Repeat(60, 15))
+	if evicted := h.Evicted(); len(evicted) != 0 {
+		t.Fatalf("evicted = %v, want none before the pod is 5 minutes old", evicted)
+	}
+
+	h.Run(controllertest.Repeat(60, 1))
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/new"}) {
+		t.Errorf("evicted = %v, want [default/new]", evicted)
+	}
+}
+
+func TestControllerTaintExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		wait    time.Duration
+		tainted bool
+	}{
+		{name: "restart before the expiry adopts the taint", wait: 5 * time.Minute, tainted: true},
+		{name: "restart after the expiry removes the taint", wait: 11 * time.Minute, tainted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config.Default()
+			conf.Taint.OnShutdown = pressurecooker.ShutdownActionExpire
+
+			h := controllertest.New(t, conf, nil)
+			h.Tick(30)
+			if err := h.Controller.Shutdown(); err != nil {
+				t.Fatalf("shutdown failed: %s", err.Error())
+			}
+			h.Wait(tt.wait)
+
+			h.Restart(conf)
+			if tainted := h.Tainted(); tainted != tt.tainted {
+				t.Errorf("tainted = %t, want %t", tainted, tt.tainted)
+			}
+			if _, ok := h.Node().Annotations[pressurecooker.TaintExpiryAnnotation]; ok {
+				t.Errorf("taint expiry annotation was not removed")
+			}
+		})
+	}
+}
+
+func TestControllerAdoptsTaint(t *testing.T) {
+	node := controllertest.NewNode(controllertest.NodeName)
+	node.Spec.Taints = []v1.Taint{pressurecooker.DefaultTaint()}
+
+	h := controllertest.New(t, config.Default(), node, controllertest.NewPod("default", "a", time.Hour))
+	h.Tick(60)
+
+	if !h.Tainted() {
+		t.Errorf("taint of a previous instance was removed")
+	}
+	if evicted := h.Evicted(); !reflect.DeepEqual(evicted, []string{"default/a"}) {
+		t.Errorf("evicted = %v, want [default/a]", evicted)
+	}
+}
+
+// TestControllerDryRunKeepsTaint checks that a dry run does not remove a
+// taint left behind by an earlier instance.
+func TestControllerDryRunKeepsTaint(t *testing.T) {
+	node := controllertest.NewNode(controllertest.NodeName)
+	node.Spec.Taints = []v1.Taint{pressurecooker.DefaultTaint()}
+	conf := config.Default()
+	conf.DryRun = true
+
+	h := controllertest.New(t, conf, node)
+	h.Run(controllertest.Concat(controllertest.Repeat(30, 1), controllertest.Repeat(10, 1)))
+
+	if !h.Tainted() {
+		t.Errorf("dry run removed the taint")
+	}
+	if h.Controller.High() {
+		t.Errorf("High() = true after recovery, want false")
+	}
+}
+
+func TestControllerIgnoresLoadErrors(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil)
+	h.Tick(30)
+
+	h.Load.Set(0, errors.New("no psi"))
+	if err := h.Controller.Sample(); err == nil {
+		t.Fatalf("expected the load error")
+	}
+
+	if !h.Tainted() {
+		t.Errorf("taint was removed after a failed sample")
+	}
+	if status := h.Controller.Watcher().Status(); status.LastError != "no psi" || !status.High {
+		t.Errorf("unexpected watcher status after a failed sample: %+v", status)
+	}
+}
+
+func TestControllerShutdown(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  pressurecooker.ShutdownAction
+		tainted bool
+	}{
+		{name: "remove", action: pressurecooker.ShutdownActionRemove, tainted: false},
+		{name: "keep", action: pressurecooker.ShutdownActionKeep, tainted: true},
+		{name: "expire", action: pressurecooker.ShutdownActionExpire, tainted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config.Default()
+			conf.Taint.OnShutdown = tt.action
+
+			h := controllertest.New(t, conf, nil)
+			h.Tick(30)
+			if err := h.Controller.Shutdown(); err != nil {
+				t.Fatalf("shutdown failed: %s", err.Error())
+			}
+
+			if tainted := h.Tainted(); tainted != tt.tainted {
+				t.Errorf("tainted = %t, want %t", tainted, tt.tainted)
+			}
+			_, expires := h.Node().Annotations[pressurecooker.TaintExpiryAnnotation]
+			if expires != (tt.action == pressurecooker.ShutdownActionExpire) {
+				t.Errorf("taint expiry annotation set = %t", expires)
+			}
+		})
+	}
+}
+
+func TestControllerRun(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil)
+	h.Load.Set(30, nil)
+
+	closeChan := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		h.Controller.Run(closeChan, nil)
+		close(done)
+	}()
+
+	// the ticker is created by Run, so keep stepping until it fired
+	deadline := time.Now().Add(5 * time.Second)
+	for !h.Tainted() {
+		if time.Now().After(deadline) {
+			t.Fatalf("node was not tainted by Run")
+		}
+		h.Wait(h.Controller.Watcher().TickerInterval)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(closeChan)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Run did not stop")
+	}
+}
+
+// TestControllerClock checks that the status, audit log and backoff all use
+// the injected clock.
+func TestControllerClock(t *testing.T) {
+	h := controllertest.New(t, config.Default(), nil, controllertest.NewPod("default", "a", time.Hour))
+
+	// taint at 03:00:15, evict at 03:00:30
+	h.Run(controllertest.Repeat(60, 2))
+
+	rec := httptest.NewRecorder()
+	h.Controller.Status().ServeHTTP(rec, httptest.NewRequest("GET", "/status", nil))
+	var st struct {
+		Time             time.Time `json:"time"`
+		BackoffRemaining string    `json:"backoffRemaining"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &st); err != nil {
+		t.Fatalf("could not decode status: %s", err.Error())
+	}
+	if want := controllertest.Start.Add(30 * time.Second); !st.Time.Equal(want) {
+		t.Errorf("status time = %s, want %s", st.Time, want)
+	}
+	if st.BackoffRemaining != "10m0s" {
+		t.Errorf("backoffRemaining = %s, want 10m0s", st.BackoffRemaining)
+	}
+
+	audited := 0
+	for _, line := range strings.Split(strings.TrimSpace(h.Audit.String()), "\n") {
+		var entry pressurecooker.AuditEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("could not decode audit entry: %s", err.Error())
+		}
+		if entry.Time.Before(controllertest.Start) || entry.Time.After(controllertest.Start.Add(30*time.Second)) {
+			t.Errorf("%s entry at %s, want a time of the fake clock", entry.Type, entry.Time)
+		}
+		audited++
+	}
+	if audited == 0 {
+		t.Errorf("nothing was audited")
+	}
+}
@@ -0,0 +1,351 @@
+// Package controllertest runs a Controller against a fake cluster and a fake
+// clock, for tests and for replaying load traces.
+package controllertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/controller"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// NodeName is the name of the node created by NewNode.
+const NodeName = "node-1"
+
+// Start is the time the fake clock starts at. The ages of pods created by
+// NewPod are relative to it.
+var Start = time.Date(2020, time.March, 12, 3, 0, 0, 0, time.UTC)
+
+var (
+	nodesResource = schema.GroupVersionResource{Version: "v1", Resource: "nodes"}
+	podsResource  = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+)
+
+// TB is the part of testing.TB used by the harness, so it can also be used
+// outside of tests.
+type TB interface {
+	Helper()
+	Fatalf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// ScriptedLoad is a LoadGetter returning the last load set for all three
+// fields.
+type ScriptedLoad struct {
+	mu   sync.Mutex
+	load float64
+	err  error
+}
+
+// Set sets the load, or the error, returned next.
+func (s *ScriptedLoad) Set(load float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.load, s.err = load, err
+}
+
+func (s *ScriptedLoad) GetLoad() (pressurecooker.Load, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return pressurecooker.Load{}, s.err
+	}
+
+	return pressurecooker.Load{Source: "psi", Smallest: s.load, Load1Min: s.load, Load5Min: s.load}, nil
+}
+
+// Harness runs a Controller against a fake clientset. The load is scripted
+// and every tick is handled synchronously, so a test is a sequence of loads
+// followed by assertions on the cluster.
+type Harness struct {
+	Client  *fake.Clientset
+	Tracker k8stesting.ObjectTracker
+	Clock   *clock.FakeClock
+	// the audit log of the controller
+	Audit      bytes.Buffer
+	Controller *controller.Controller
+	// the load set by Tick. It is only used by the controller if no other
+	// LoadGetter was passed to NewWithLoad.
+	Load *ScriptedLoad
+
+	tb       TB
+	nodeName string
+	taintKey string
+	getter   pressurecooker.LoadGetter
+}
+
+// New creates a harness with a scripted load. node defaults to NewNode().
+func New(tb TB, conf config.Config, node *v1.Node, objects ...runtime.Object) *Harness {
+	tb.Helper()
+
+	return NewWithLoad(tb, conf, node, nil, objects...)
+}
+
+// NewWithLoad uses lg instead of the scripted load, e.g. to replay a load
+// trace.
+func NewWithLoad(tb TB, conf config.Config, node *v1.Node, lg pressurecooker.LoadGetter, objects ...runtime.Object) *Harness {
+	tb.Helper()
+
+	if node == nil {
+		node = NewNode(NodeName)
+	}
+	// like fake.NewSimpleClientset, but with access to the tracker
+	tracker := k8stesting.NewObjectTracker(scheme.Scheme, scheme.Codecs.UniversalDecoder())
+	for _, obj := range append([]runtime.Object{node}, objects...) {
+		if err := tracker.Add(obj); err != nil {
+			tb.Fatalf("could not add %v: %s", obj, err.Error())
+		}
+	}
+	client := &fake.Clientset{}
+	client.AddReactor("create", "events", discardReactor)
+	client.AddReactor("patch", "nodes", mergePatchReactor(tracker))
+	client.AddReactor("create", "pods", evictionReactor(tracker))
+	client.AddReactor("*", "*", k8stesting.ObjectReaction(tracker))
+
+	h := &Harness{
+		Client:   client,
+		Tracker:  tracker,
+		Clock:    clock.NewFakeClock(Start),
+		tb:       tb,
+		nodeName: node.Name,
+		Load:     &ScriptedLoad{},
+		getter:   lg,
+	}
+	if h.getter == nil {
+		h.getter = h.Load
+	}
+	h.Restart(conf)
+
+	return h
+}
+
+// Restart replaces the controller by a new instance with conf, as after a
+// restart of the process.
+func (h *Harness) Restart(conf config.Config) {
+	h.tb.Helper()
+
+	ctrl, err := controller.NewController(h.Client, h.getter, h.Clock, controller.Options{
+		NodeName: h.nodeName,
+		UsePSI:   true,
+		Base:     conf,
+		AuditLog: pressurecooker.NewAuditLog(h.nodeName, &h.Audit),
+	})
+	if err != nil {
+		h.tb.Fatalf("could not create controller: %s", err.Error())
+	}
+	if err := ctrl.Start(); err != nil {
+		h.tb.Fatalf("could not start controller: %s", err.Error())
+	}
+	h.Controller = ctrl
+	h.taintKey = conf.Taint.Key
+}
+
+// Tick advances the clock by one interval and samples load.
+func (h *Harness) Tick(load float64) {
+	h.tb.Helper()
+
+	h.Clock.Step(h.Controller.Watcher().TickerInterval)
+	h.Load.Set(load, nil)
+
+	if err := h.Controller.Sample(); err != nil {
+		h.tb.Fatalf("could not sample load: %s", err.Error())
+	}
+}
+
+// Replay samples every load of r at its recorded time. Recorded errors are
+// logged and skipped, like Run does.
+func (h *Harness) Replay(r *pressurecooker.ReplayLoadGetter) {
+	for {
+		next, ok := r.Next()
+		if !ok {
+			return
+		}
+
+		h.Clock.SetTime(next)
+		if err := h.Controller.Sample(); err != nil {
+			h.tb.Logf("%s: %s", next.Format(time.RFC3339), err.Error())
+		}
+	}
+}
+
+// Wait advances the clock by d without sampling.
+func (h *Harness) Wait(d time.Duration) {
+	h.Clock.Step(d)
+}
+
+// Run ticks once per load.
+func (h *Harness) Run(loads []float64) {
+	h.tb.Helper()
+
+	for _, load := range loads {
+		h.Tick(load)
+	}
+}
+
+// Node returns the current state of the node.
+func (h *Harness) Node() *v1.Node {
+	h.tb.Helper()
+
+	obj, err := h.Tracker.Get(nodesResource, "", h.nodeName)
+	if err != nil {
+		h.tb.Fatalf("could not get node: %s", err.Error())
+	}
+
+	return obj.(*v1.Node)
+}
+
+// Tainted reports whether the node carries the configured taint.
+func (h *Harness) Tainted() bool {
+	h.tb.Helper()
+
+	for _, taint := range h.Node().Spec.Taints {
+		if taint.Key == h.taintKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Evicted returns the namespace/name of all pods the controller tried to
+// evict, in order.
+func (h *Harness) Evicted() []string {
+	var evicted []string
+	for _, a := range h.Client.Actions() {
+		if a.GetVerb() != "create" || a.GetSubresource() != "eviction" {
+			continue
+		}
+
+		eviction := a.(k8stesting.CreateAction).GetObject().(*v1beta1.Eviction)
+		evicted = append(evicted, eviction.Namespace+"/"+eviction.Name)
+	}
+
+	return evicted
+}
+
+// mergePatchReactor adds JSON merge patches, which the object tracker does
+// not support, for nodes.
+func mergePatchReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.MergePatchType {
+			return false, nil, nil
+		}
+
+		obj, err := tracker.Get(nodesResource, "", patch.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return true, nil, err
+		}
+		modified, err := jsonpatch.MergePatch(original, patch.GetPatch())
+		if err != nil {
+			return true, nil, err
+		}
+
+		node := &v1.Node{}
+		if err := json.Unmarshal(modified, node); err != nil {
+			return true, nil, err
+		}
+
+		return true, node, tracker.Update(nodesResource, node, "")
+	}
+}
+
+// discardReactor accepts objects without storing them. Events are created
+// in the namespace of the event, which the tracker does not expect.
+func discardReactor(action k8stesting.Action) (bool, runtime.Object, error) {
+	return true, action.(k8stesting.CreateAction).GetObject(), nil
+}
+
+// evictionReactor deletes evicted pods. The fake clientset would otherwise
+// try to store the Eviction as a pod.
+func evictionReactor(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+
+		eviction := action.(k8stesting.CreateAction).GetObject().(*v1beta1.Eviction)
+		return true, nil, tracker.Delete(podsResource, eviction.Namespace, eviction.Name)
+	}
+}
+
+// NewNode returns an untainted node without labels.
+func NewNode(name string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			UID:    types.UID(name + "-uid"),
+			Labels: map[string]string{},
+		},
+	}
+}
+
+// NewPod returns a burstable pod of a ReplicaSet on NodeName that started
+// age before Start.
+func NewPod(namespace, name string, age time.Duration) *v1.Pod {
+	controller := true
+
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			// required for events
+			SelfLink: "/api/v1/namespaces/" + namespace + "/pods/" + name,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "ReplicaSet",
+				Name:       name + "-rs",
+				Controller: &controller,
+			}},
+		},
+		Spec: v1.PodSpec{
+			NodeName: NodeName,
+		},
+		Status: v1.PodStatus{
+			Phase:     v1.PodRunning,
+			QOSClass:  v1.PodQOSBurstable,
+			StartTime: &metav1.Time{Time: Start.Add(-age)},
+		},
+	}
+}
+
+// Repeat returns load n times, to build load sequences.
+func Repeat(load float64, n int) []float64 {
+	loads := make([]float64, n)
+	for i := range loads {
+		loads[i] = load
+	}
+
+	return loads
+}
+
+// Concat joins load sequences.
+func Concat(sequences ...[]float64) []float64 {
+	var loads []float64
+	for _, s := range sequences {
+		loads = append(loads, s...)
+	}
+
+	return loads
+}
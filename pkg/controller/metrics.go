@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	prometheusNamespace       = "pressurecooker"
+	pressureThresholdExceeded = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "pressure_threshold_exceeded",
+		Help:      "cpu pressure is currently above (1) or below (0) threshold",
+	})
+	pressureThresholdExceededTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "pressure_threshold_exceeded_total",
+		Help:      "number of times the pressure threshold was exceeded",
+	})
+	nodeTainted = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "tainted",
+		Help:      "the node carries the taint (1) or not (0)",
+	})
+	pressureRecoveredTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "pressure_recovered_total",
+		Help:      "number of times the pressure on the node recovered",
+	})
+	pressureDryRun = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "dry_run",
+		Help:      "action is running in dry-run mode (1) or not (0)",
+	}, []string{"action"})
+	pressureThreshold = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "threshold",
+		Help:      "configured threshold by action (taint or evict) and load source (psi or loadavg)",
+	}, []string{"action", "source"})
+	pressureEnabled = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "enabled",
+		Help:      "action is enabled (1) or disabled (0), with the reason it is disabled",
+	}, []string{"action", "reason"})
+)
+
+// RegisterMetrics registers the metrics of this package with r.
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(pressureThresholdExceeded)
+	r.MustRegister(pressureThresholdExceededTotal)
+	r.MustRegister(pressureRecoveredTotal)
+	r.MustRegister(nodeTainted)
+	r.MustRegister(pressureEnabled)
+	r.MustRegister(pressureDryRun)
+	r.MustRegister(pressureThreshold)
+}
+
+func setDryRunMetric(action string, dryRun bool) {
+	if dryRun {
+		pressureDryRun.WithLabelValues(action).Set(1)
+	} else {
+		pressureDryRun.WithLabelValues(action).Set(0)
+	}
+}
@@ -0,0 +1,100 @@
+package controller_test
+
+import (
+	"bytes"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/controller/controllertest"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// TestReplayIncident replays testdata/incident.jsonl, a hand-written trace
+// in the recording format with one sample every 15 seconds from 03:00:15 to
+// 03:20: a constant load of 12, then 31 (taint) from 03:05, 72 (evict) from
+// 03:10 and 15 (recovery) from 03:17, with one failed sample at 03:08. With
+// an eviction backoff of one minute six pods are evicted.
+func TestReplayIncident(t *testing.T) {
+	f, err := os.Open("testdata/incident.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	trace, err := pressurecooker.NewReplayLoadGetter(f)
+	if err != nil {
+		t.Fatalf("could not read trace: %s", err.Error())
+	}
+
+	conf := config.Default()
+	conf.Eviction.Backoff.Duration = time.Minute
+
+	var pods []runtime.Object
+	for i, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		pods = append(pods, controllertest.NewPod("default", name, time.Duration(i+1)*24*time.Hour))
+	}
+
+	h := controllertest.NewWithLoad(t, conf, nil, trace, pods...)
+	h.Replay(trace)
+
+	var times []string
+	for _, r := range h.Controller.Evicter().History() {
+		times = append(times, r.Time.Format("15:04:05"))
+	}
+	want := []string{"03:10:00", "03:11:15", "03:12:30", "03:13:45", "03:15:00", "03:16:15"}
+	if !reflect.DeepEqual(times, want) {
+		t.Errorf("evictions at %v, want %v", times, want)
+	}
+	if evicted := h.Evicted(); len(evicted) != len(want) {
+		t.Errorf("evicted %v, want %d pods", evicted, len(want))
+	}
+	if h.Tainted() {
+		t.Errorf("node is still tainted after the load dropped")
+	}
+}
+
+// TestRecordReplay records a scripted run and replays it against a new
+// cluster, which must end up with the same evictions.
+func TestRecordReplay(t *testing.T) {
+	pods := func() []runtime.Object {
+		return []runtime.Object{
+			controllertest.NewPod("default", "a", time.Hour),
+			controllertest.NewPod("default", "b", 48*time.Hour),
+		}
+	}
+
+	var buf bytes.Buffer
+	scripted := &controllertest.ScriptedLoad{}
+	recorder := pressurecooker.NewRecordingLoadGetter(scripted, controllertest.NodeName, &buf)
+
+	recorded := controllertest.NewWithLoad(t, config.Default(), nil, recorder, pods()...)
+	recorder.SetClock(recorded.Clock)
+	// tick scripts the load behind the recorder
+	recorded.Load = scripted
+	recorded.Run(controllertest.Concat(controllertest.Repeat(30, 4), controllertest.Repeat(60, 50), controllertest.Repeat(10, 2)))
+	if n := len(recorded.Evicted()); n != 2 {
+		t.Fatalf("recorded run evicted %d pods, want 2", n)
+	}
+
+	trace, err := pressurecooker.NewReplayLoadGetter(&buf)
+	if err != nil {
+		t.Fatalf("could not read recorded trace: %s", err.Error())
+	}
+	if n := len(trace.Samples()); n != 56 {
+		t.Fatalf("recorded %d samples, want 56", n)
+	}
+
+	replayed := controllertest.NewWithLoad(t, config.Default(), nil, trace, pods()...)
+	replayed.Replay(trace)
+
+	if got, want := replayed.Controller.Evicter().History(), recorded.Controller.Evicter().History(); !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed evictions %v, recorded %v", got, want)
+	}
+	if replayed.Tainted() != recorded.Tainted() {
+		t.Errorf("replayed tainted = %t, recorded %t", replayed.Tainted(), recorded.Tainted())
+	}
+}
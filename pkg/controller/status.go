@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type actionStatus struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type nodeStatus struct {
+	Node      string              `json:"node"`
+	Time      time.Time           `json:"time"`
+	Load      pressurecooker.Load `json:"load"`
+	SampledAt time.Time           `json:"sampledAt"`
+	// "high" or "low"
+	State      string `json:"state"`
+	Thresholds struct {
+		Taint float64 `json:"taint"`
+		Evict float64 `json:"evict"`
+	} `json:"thresholds"`
+	Tainted          bool                             `json:"tainted"`
+	Profile          string                           `json:"profile,omitempty"`
+	Taint            actionStatus                     `json:"taint"`
+	Evict            actionStatus                     `json:"evict"`
+	BackoffRemaining string                           `json:"backoffRemaining"`
+	Events           []pressurecooker.ThresholdRecord `json:"events"`
+	Evictions        []pressurecooker.EvictionRecord  `json:"evictions"`
+}
+
+// statusServer serves /status. The watcher can be queried at any time, the
+// state owned by the main loop is copied in by update.
+type statusServer struct {
+	nodeName string
+	watcher  *pressurecooker.Watcher
+	clock    clock.Clock
+
+	mu             sync.Mutex
+	tainted        bool
+	profile        string
+	enabled        pressurecooker.SwitchState
+	evictThreshold float64
+	backoffUntil   time.Time
+	evictions      []pressurecooker.EvictionRecord
+}
+
+// update must be called from the main loop.
+func (s *statusServer) update(tainted bool, profile string, enabled pressurecooker.SwitchState, e *pressurecooker.Evicter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tainted = tainted
+	s.profile = profile
+	s.enabled = enabled
+	s.evictThreshold = e.Threshold()
+	s.backoffUntil = e.BackoffUntil()
+	s.evictions = e.History()
+}
+
+func (s *statusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws := s.watcher.Status()
+	now := s.clock.Now()
+
+	st := nodeStatus{
+		Node:      s.nodeName,
+		Time:      now.UTC(),
+		Load:      ws.Load,
+		SampledAt: ws.SampledAt,
+		State:     "low",
+		Events:    ws.Events,
+	}
+	if ws.High {
+		st.State = "high"
+	}
+	st.Thresholds.Taint = ws.Threshold
+
+	s.mu.Lock()
+	st.Thresholds.Evict = s.evictThreshold
+	st.Tainted = s.tainted
+	st.Profile = s.profile
+	st.Taint = actionStatus{Enabled: s.enabled.Taint == "", Reason: s.enabled.Taint}
+	st.Evict = actionStatus{Enabled: s.enabled.Evict == "", Reason: s.enabled.Evict}
+	remaining := s.backoffUntil.Sub(now)
+	st.Evictions = s.evictions
+	s.mu.Unlock()
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	st.BackoffRemaining = remaining.Round(time.Second).String()
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(&st)
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+)
+
+// applySwitches enables tainting and evicting as far as the configuration
+// and the disable switches allow. When tainting gets disabled, an existing
+// taint is removed. It sets c.enabled to the state including the
+// configuration.
+func (c *Controller) applySwitches() {
+	conf, state, previous := c.conf, c.switchState, c.enabled
+	t, e := c.tainter, c.evicter
+
+	if !conf.Taint.Enabled && state.Taint == "" {
+		state.Taint = pressurecooker.DisabledByConfig
+	}
+	if !conf.Eviction.Enabled && state.Evict == "" {
+		state.Evict = pressurecooker.DisabledByConfig
+	}
+
+	taintEnabled := state.Taint == ""
+	t.SetEnabled(taintEnabled)
+	e.SetEnabled(state.Evict == "")
+
+	pressureEnabled.Reset()
+	pressureEnabled.WithLabelValues("taint", state.Taint).Set(boolToFloat(taintEnabled))
+	pressureEnabled.WithLabelValues("evict", state.Evict).Set(boolToFloat(state.Evict == ""))
+
+	if previous.Taint != state.Taint {
+		c.auditSwitch("taint", state.Taint)
+	}
+	if previous.Evict != state.Evict {
+		c.auditSwitch("evict", state.Evict)
+	}
+
+	if previous.Taint == "" && !taintEnabled {
+		glog.Infof("tainting disabled (%s)", state.Taint)
+		if c.isTainted {
+			c.untaint(pressurecooker.ThresholdEvent{})
+		}
+	}
+	if previous.Taint != "" && taintEnabled {
+		glog.Infof("tainting enabled")
+	}
+	if previous.Evict != state.Evict {
+		if state.Evict == "" {
+			glog.Infof("evicting enabled")
+		} else {
+			glog.Infof("evicting disabled (%s)", state.Evict)
+		}
+	}
+
+	c.enabled = state
+}
+
+func (c *Controller) auditSwitch(action, reason string) {
+	state := "enabled"
+	if reason != "" {
+		state = "disabled"
+	}
+
+	c.audit.Log(pressurecooker.AuditEntry{
+		Type:   pressurecooker.AuditSwitch,
+		Action: action,
+		State:  state,
+		Reason: reason,
+	})
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,339 @@
+// Package notify delivers pressurecooker notifications to webhooks.
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// WebhookURLAnnotation on a Namespace adds a webhook for the pods in that
+// namespace, WebhookFormatAnnotation sets its Format.
+const (
+	WebhookURLAnnotation    = "pressurecooker/webhook-url"
+	WebhookFormatAnnotation = "pressurecooker/webhook-format"
+)
+
+type Format string
+
+const (
+	// FormatJSON posts the Notification as it is.
+	FormatJSON Format = "json"
+	// FormatSlack posts a Slack compatible {"text": ...} message.
+	FormatSlack Format = "slack"
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatJSON, FormatSlack:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown webhook format %q", s)
+}
+
+const (
+	queueLength = 100
+	maxAttempts = 5
+	// doubled after every failed attempt
+	initialBackoff = time.Second
+)
+
+type destination struct {
+	url    string
+	format Format
+}
+
+type delivery struct {
+	format       Format
+	notification pressurecooker.Notification
+}
+
+// ParseAllowlist parses a comma separated list of URL prefixes for
+// namespace routing. Every prefix needs a scheme and a host.
+func ParseAllowlist(list string) ([]*url.URL, error) {
+	var allowlist []*url.URL
+	for _, raw := range strings.Split(list, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("%s is not an http(s) URL", raw)
+		}
+		allowlist = append(allowlist, u)
+	}
+
+	return allowlist, nil
+}
+
+// allowed reports whether raw has the scheme and host of a prefix in the
+// allowlist and its path is below the path of that prefix. Hosts are
+// compared as a whole, so https://example.com does not allow
+// https://example.com.evil.org.
+func allowed(allowlist []*url.URL, raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.User != nil {
+		return false
+	}
+
+	for _, prefix := range allowlist {
+		if u.Scheme != prefix.Scheme || !strings.EqualFold(u.Host, prefix.Host) {
+			continue
+		}
+
+		dir := strings.TrimSuffix(prefix.Path, "/")
+		if u.Path == dir || strings.HasPrefix(u.Path, dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Webhook posts notifications to a fixed list of URLs and, with namespace
+// routing, to the URL annotated on the namespace of the pod if it is in the
+// allowlist. Every URL has
+// its own queue and retries, so a slow or failing webhook does not delay the
+// others.
+type Webhook struct {
+	client       kubernetes.Interface
+	destinations []destination
+	// namespace routing is disabled if empty
+	namespaceAllowlist []*url.URL
+	http               *http.Client
+	backoff            time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	queue  chan pressurecooker.Notification
+	// closed by Close when the queues are not drained in time
+	abort chan struct{}
+	// closed by Run when all queues are drained
+	done chan struct{}
+}
+
+// NewWebhook creates a webhook for urls. Namespace routing is enabled if
+// namespaceAllowlist is not empty, see ParseAllowlist.
+func NewWebhook(client kubernetes.Interface, urls []string, format Format, namespaceAllowlist []*url.URL) *Webhook {
+	w := &Webhook{
+		client:             client,
+		namespaceAllowlist: namespaceAllowlist,
+		http:               &http.Client{Timeout: 10 * time.Second},
+		backoff:            initialBackoff,
+		queue:              make(chan pressurecooker.Notification, queueLength),
+		abort:              make(chan struct{}),
+		done:               make(chan struct{}),
+	}
+	for _, url := range urls {
+		w.destinations = append(w.destinations, destination{url: url, format: format})
+	}
+
+	return w
+}
+
+// Notify queues n. If the queue is full or the webhook is closed, n is
+// dropped.
+func (w *Webhook) Notify(n pressurecooker.Notification) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		glog.Warningf("webhook is closed, dropping %s notification", n.Type)
+		return
+	}
+
+	select {
+	case w.queue <- n:
+	default:
+		glog.Warningf("webhook queue is full, dropping %s notification", n.Type)
+	}
+}
+
+// Run delivers queued notifications until Close is called and all queues
+// are drained.
+func (w *Webhook) Run() {
+	defer close(w.done)
+
+	var wg sync.WaitGroup
+	workers := make(map[string]chan delivery)
+
+	for n := range w.queue {
+		for _, d := range w.route(n) {
+			queue, ok := workers[d.url]
+			if !ok {
+				queue = make(chan delivery, queueLength)
+				workers[d.url] = queue
+
+				wg.Add(1)
+				go func(url string) {
+					defer wg.Done()
+					w.work(url, queue)
+				}(d.url)
+			}
+
+			select {
+			case queue <- delivery{format: d.format, notification: n}:
+			default:
+				glog.Warningf("webhook queue of %s is full, dropping %s notification", redact(d.url), n.Type)
+			}
+		}
+	}
+
+	for _, queue := range workers {
+		close(queue)
+	}
+	wg.Wait()
+}
+
+// Close stops accepting notifications and waits until the queued ones are
+// delivered. After timeout, retries and the remaining notifications are
+// given up; only requests in flight are waited for.
+func (w *Webhook) Close(timeout time.Duration) {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-time.After(timeout):
+		glog.Warningf("webhook queues not drained after %s, giving up", timeout)
+		close(w.abort)
+		<-w.done
+	}
+}
+
+func (w *Webhook) work(url string, queue chan delivery) {
+	for d := range queue {
+		select {
+		case <-w.abort:
+			glog.Errorf("dropping %s notification to %s on shutdown", d.notification.Type, redact(url))
+			continue
+		default:
+		}
+
+		w.deliver(destination{url: url, format: d.format}, d.notification)
+	}
+}
+
+func (w *Webhook) route(n pressurecooker.Notification) []destination {
+	if len(w.namespaceAllowlist) == 0 || n.Namespace == "" {
+		return w.destinations
+	}
+
+	ns, err := w.client.CoreV1().Namespaces().Get(n.Namespace, metav1.GetOptions{})
+	if err != nil {
+		glog.Errorf("could not look up webhook of namespace %s: %s", n.Namespace, err.Error())
+		return w.destinations
+	}
+
+	target := ns.Annotations[WebhookURLAnnotation]
+	if target == "" {
+		return w.destinations
+	}
+	if !allowed(w.namespaceAllowlist, target) {
+		glog.Warningf("namespace %s: webhook %s is not in -webhook-namespace-allowlist, ignoring it", n.Namespace, redact(target))
+		return w.destinations
+	}
+
+	format := FormatJSON
+	if raw, ok := ns.Annotations[WebhookFormatAnnotation]; ok {
+		if format, err = ParseFormat(raw); err != nil {
+			glog.Warningf("namespace %s: %s, using %s", n.Namespace, err.Error(), FormatJSON)
+			format = FormatJSON
+		}
+	}
+
+	return append(append([]destination(nil), w.destinations...), destination{url: target, format: format})
+}
+
+func (w *Webhook) deliver(d destination, n pressurecooker.Notification) {
+	body, err := payload(d.format, n)
+	if err != nil {
+		glog.Errorf("could not encode %s notification: %s", n.Type, err.Error())
+		return
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(d.url, body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt == maxAttempts {
+			glog.Errorf("giving up on %s notification to %s after %d attempts: %s", n.Type, redact(d.url), attempt, err.Error())
+			return
+		}
+
+		glog.Warningf("could not send %s notification to %s, retrying in %s: %s", n.Type, redact(d.url), backoff, err.Error())
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-w.abort:
+			glog.Errorf("giving up on %s notification to %s on shutdown: %s", n.Type, redact(d.url), err.Error())
+			return
+		}
+	}
+}
+
+// post sends body to url. It reports whether a failed request should be
+// retried.
+func (w *Webhook) post(url string, body []byte) (bool, error) {
+	resp, err := w.http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+func payload(format Format, n pressurecooker.Notification) ([]byte, error) {
+	if format == FormatSlack {
+		icon := ":information_source:"
+		if n.Level == "warning" {
+			icon = ":warning:"
+		}
+		return json.Marshal(map[string]string{
+			"text": fmt.Sprintf("%s %s", icon, n.Text()),
+		})
+	}
+
+	return json.Marshal(&n)
+}
+
+// redact drops the path of url, which often contains a secret token.
+func redact(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		if j := strings.Index(url[i+3:], "/"); j >= 0 {
+			return url[:i+3+j] + "/..."
+		}
+	}
+
+	return url
+}
@@ -0,0 +1,260 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// receiver is a webhook endpoint answering with the given status codes in
+// order, and with the last one after that.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []map[string]interface{}
+	requests int
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("could not decode webhook payload: %s", err.Error())
+		}
+
+		r.mu.Lock()
+		status := r.statuses[len(r.statuses)-1]
+		if r.requests < len(r.statuses) {
+			status = r.statuses[r.requests]
+		}
+		r.requests++
+		if status < 300 {
+			r.bodies = append(r.bodies, body)
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *receiver) received() ([]map[string]interface{}, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]map[string]interface{}(nil), r.bodies...), r.requests
+}
+
+func newTestWebhook(client *fake.Clientset, urls []string, format Format, namespaceAllowlist []*url.URL) *Webhook {
+	w := NewWebhook(client, urls, format, namespaceAllowlist)
+	w.backoff = time.Millisecond
+	go w.Run()
+
+	return w
+}
+
+func eviction(namespace string) pressurecooker.Notification {
+	return pressurecooker.Notification{
+		Node:      "node-1",
+		Type:      pressurecooker.NotificationEviction,
+		Level:     "warning",
+		Pod:       namespace + "/a",
+		Namespace: namespace,
+		Reason:    pressurecooker.ReasonPodEvicted,
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		statuses  []int
+		delivered int
+		requests  int
+	}{
+		{
+			name:      "success",
+			statuses:  []int{http.StatusOK},
+			delivered: 1,
+			requests:  1,
+		},
+		{
+			name:      "retried after server errors",
+			statuses:  []int{http.StatusBadGateway, http.StatusTooManyRequests, http.StatusOK},
+			delivered: 1,
+			requests:  3,
+		},
+		{
+			name:     "no retry after client errors",
+			statuses: []int{http.StatusBadRequest},
+			requests: 1,
+		},
+		{
+			name:     "gives up after the last attempt",
+			statuses: []int{http.StatusServiceUnavailable},
+			requests: maxAttempts,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newReceiver(t, test.statuses...)
+			w := newTestWebhook(fake.NewSimpleClientset(), []string{r.URL}, FormatJSON, nil)
+
+			w.Notify(eviction("default"))
+			w.Close(5 * time.Second)
+
+			bodies, requests := r.received()
+			if len(bodies) != test.delivered || requests != test.requests {
+				t.Errorf("delivered %d notifications in %d requests, want %d in %d", len(bodies), requests, test.delivered, test.requests)
+			}
+		})
+	}
+}
+
+// TestWebhookIndependentDestinations checks that a failing webhook does not
+// delay the others.
+func TestWebhookIndependentDestinations(t *testing.T) {
+	failing := newReceiver(t, http.StatusServiceUnavailable)
+	working := newReceiver(t, http.StatusOK)
+
+	w := NewWebhook(fake.NewSimpleClientset(), []string{failing.URL, working.URL}, FormatJSON, nil)
+	w.backoff = time.Hour
+	go w.Run()
+
+	for i := 0; i < 3; i++ {
+		w.Notify(eviction("default"))
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if bodies, _ := working.received(); len(bodies) == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("notifications were not delivered while another webhook was retrying")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w.Close(100 * time.Millisecond)
+	if _, requests := failing.received(); requests != 1 {
+		t.Errorf("failing webhook got %d requests, want 1 before shutdown", requests)
+	}
+}
+
+// TestWebhookClose checks that notifications queued right before Close, like
+// the untaint on shutdown, are still delivered.
+func TestWebhookClose(t *testing.T) {
+	r := newReceiver(t, http.StatusOK)
+	w := newTestWebhook(fake.NewSimpleClientset(), []string{r.URL}, FormatJSON, nil)
+
+	w.Notify(eviction("default"))
+	w.Notify(pressurecooker.Notification{Node: "node-1", Type: pressurecooker.NotificationUntaint, Level: "info"})
+	w.Close(5 * time.Second)
+
+	bodies, _ := r.received()
+	if len(bodies) != 2 || bodies[1]["type"] != string(pressurecooker.NotificationUntaint) {
+		t.Errorf("delivered %v, want the eviction and the untaint", bodies)
+	}
+
+	// dropped without panicking
+	w.Notify(eviction("default"))
+}
+
+func TestWebhookNamespaceRouting(t *testing.T) {
+	global := newReceiver(t, http.StatusOK)
+	team := newReceiver(t, http.StatusOK)
+	other := newReceiver(t, http.StatusOK)
+
+	namespace := func(name, url string) *v1.Namespace {
+		return &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name,
+				Annotations: map[string]string{
+					WebhookURLAnnotation:    url,
+					WebhookFormatAnnotation: string(FormatSlack),
+				},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(
+		namespace("team", team.URL+"/hooks/team"),
+		namespace("other", other.URL+"/hooks/other"),
+	)
+	allowlist, err := ParseAllowlist(team.URL + "/hooks/")
+	if err != nil {
+		t.Fatalf("could not parse allowlist: %s", err.Error())
+	}
+	w := newTestWebhook(client, []string{global.URL}, FormatJSON, allowlist)
+
+	w.Notify(eviction("team"))
+	w.Notify(eviction("other"))
+	w.Notify(eviction("default"))
+	w.Close(5 * time.Second)
+
+	if bodies, _ := global.received(); len(bodies) != 3 {
+		t.Errorf("global webhook got %d notifications, want 3", len(bodies))
+	}
+	if _, requests := other.received(); requests != 0 {
+		t.Errorf("webhook outside of the allowlist got %d requests, want 0", requests)
+	}
+	bodies, _ := team.received()
+	if len(bodies) != 1 {
+		t.Fatalf("namespace webhook got %d notifications, want 1", len(bodies))
+	}
+	if _, ok := bodies[0]["text"]; !ok {
+		t.Errorf("namespace webhook got %v, want a slack message", bodies[0])
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	allowlist, err := ParseAllowlist("https://hooks.slack.com/services/, http://alerts.example.com:8080")
+	if err != nil {
+		t.Fatalf("could not parse allowlist: %s", err.Error())
+	}
+
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://hooks.slack.com/services/T1/B2/x", true},
+		{"https://HOOKS.slack.com/services/T1", true},
+		{"https://hooks.slack.com/servicesX", false},
+		{"https://hooks.slack.com/", false},
+		{"http://hooks.slack.com/services/T1", false},
+		{"https://hooks.slack.com.evil.org/services/T1", false},
+		{"https://user@hooks.slack.com/services/T1", false},
+		{"http://alerts.example.com:8080/any/path", true},
+		{"http://alerts.example.com/any/path", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"://invalid", false},
+	}
+
+	for _, tt := range tests {
+		if got := allowed(allowlist, tt.url); got != tt.want {
+			t.Errorf("allowed(%s) = %t, want %t", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	for _, list := range []string{"hooks.slack.com", "ftp://example.com/", "https://"} {
+		if _, err := ParseAllowlist(list); err == nil {
+			t.Errorf("ParseAllowlist(%s) succeeded, want an error", list)
+		}
+	}
+}
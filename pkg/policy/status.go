@@ -0,0 +1,117 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
+)
+
+// reservedLabelPrefix is the prefix of the labels pressurecooker reads from
+// its own node. Policies must not select on them, as switching a policy
+// based on them would feed back into the policy in use.
+const reservedLabelPrefix = "pressurecooker."
+
+// Adopt records in the status of all policies that this node uses the
+// policy name, or none if name is empty. Only policies whose status changes
+// are written. Status writes do not change the generation of a policy, so
+// they do not make the other nodes reload their configuration.
+func (w *Watcher) Adopt(name string) error {
+	objs, err := w.informer.Lister().List(everything)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		// the cached copy avoids a GET per policy; on a conflict the policy
+		// is read again
+		cached := u.DeepCopy()
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			if cached == nil {
+				fresh, err := w.client.Resource(Resource).Get(u.GetName(), metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				cached = fresh
+			}
+			current := cached
+			cached = nil
+
+			nodes, _, err := unstructured.NestedStringSlice(current.Object, "status", "nodes")
+			if err != nil {
+				return err
+			}
+
+			updated := withoutNode(nodes, w.nodeName)
+			if current.GetName() == name {
+				updated = append(updated, w.nodeName)
+				sort.Strings(updated)
+			}
+			if equalStrings(nodes, updated) {
+				return nil
+			}
+
+			if err := unstructured.SetNestedStringSlice(current.Object, updated, "status", "nodes"); err != nil {
+				return err
+			}
+
+			_, err = w.client.Resource(Resource).UpdateStatus(current, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSelector rejects selectors on the labels pressurecooker reads
+// from its own node.
+func validateSelector(s metav1.LabelSelector) error {
+	keys := make([]string, 0, len(s.MatchLabels)+len(s.MatchExpressions))
+	for key := range s.MatchLabels {
+		keys = append(keys, key)
+	}
+	for _, r := range s.MatchExpressions {
+		keys = append(keys, r.Key)
+	}
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, reservedLabelPrefix) {
+			return fmt.Errorf("nodeSelector must not use the label %s", key)
+		}
+	}
+
+	return nil
+}
+
+func withoutNode(nodes []string, nodeName string) []string {
+	result := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		if n != nodeName {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
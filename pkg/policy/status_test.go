@@ -0,0 +1,107 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func testPolicy(name string, nodes ...string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion(Resource.GroupVersion().String())
+	u.SetKind("PressurePolicy")
+	u.SetName(name)
+	if len(nodes) > 0 {
+		unstructured.SetNestedStringSlice(u.Object, nodes, "status", "nodes")
+	}
+	return u
+}
+
+func TestAdopt(t *testing.T) {
+	policies := []*unstructured.Unstructured{
+		testPolicy("batch", "node-0"),
+		testPolicy("web", "node-1", "node-2"),
+	}
+	var objects []runtime.Object
+	for _, p := range policies {
+		objects = append(objects, p.DeepCopy())
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), objects...)
+
+	w := NewWatcher(client, "node-1")
+	// fills the cache without running the informer
+	for _, p := range policies {
+		if err := w.informer.Informer().GetStore().Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Adopt("batch"); err != nil {
+		t.Fatalf("could not adopt policy: %s", err.Error())
+	}
+
+	updates := 0
+	for _, a := range client.Actions() {
+		if a.GetVerb() == "get" {
+			t.Errorf("policy was read from the API instead of the cache: %v", a)
+		}
+		if a.GetVerb() == "update" {
+			updates++
+		}
+	}
+	if updates != 2 {
+		t.Errorf("%d status updates, want 2", updates)
+	}
+
+	want := map[string][]string{
+		"batch": {"node-0", "node-1"},
+		"web":   {"node-2"},
+	}
+	for name, nodes := range want {
+		u, err := client.Resource(Resource).Get(name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("could not get policy %s: %s", name, err.Error())
+		}
+		got, _, _ := unstructured.NestedStringSlice(u.Object, "status", "nodes")
+		if !reflect.DeepEqual(got, nodes) {
+			t.Errorf("status.nodes of %s = %v, want %v", name, got, nodes)
+		}
+	}
+}
+
+func TestValidateSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector metav1.LabelSelector
+		valid    bool
+	}{
+		{
+			name:     "node pool",
+			selector: metav1.LabelSelector{MatchLabels: map[string]string{"node-pool": "batch"}},
+			valid:    true,
+		},
+		{
+			name:     "own label",
+			selector: metav1.LabelSelector{MatchLabels: map[string]string{"pressurecooker.enabled": "true"}},
+		},
+		{
+			name: "own label in an expression",
+			selector: metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "pressurecooker.policy",
+				Operator: metav1.LabelSelectorOpExists,
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateSelector(tt.selector); (err == nil) != tt.valid {
+				t.Errorf("validateSelector() = %v, want valid = %t", err, tt.valid)
+			}
+		})
+	}
+}
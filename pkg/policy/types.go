@@ -0,0 +1,34 @@
+package policy
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var Resource = schema.GroupVersionResource{
+	Group:    "pressurecooker.io",
+	Version:  "v1alpha1",
+	Resource: "pressurepolicies",
+}
+
+// PressurePolicy is a cluster-scoped policy for the nodes matching its
+// selector. Besides the fields below, the spec may contain every field of
+// the configuration file except apiVersion, kind and profiles.
+type PressurePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PressurePolicySpec   `json:"spec"`
+	Status PressurePolicyStatus `json:"status,omitempty"`
+}
+
+type PressurePolicySpec struct {
+	NodeSelector metav1.LabelSelector `json:"nodeSelector"`
+	// if several policies match a node, the one with the highest priority wins
+	Priority int32 `json:"priority"`
+}
+
+type PressurePolicyStatus struct {
+	// nodes that currently use this policy
+	Nodes []string `json:"nodes,omitempty"`
+}
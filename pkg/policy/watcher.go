@@ -0,0 +1,206 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/rtreffer/kubernetes-pressurecooker/pkg/config"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+var everything = labels.Everything()
+
+// Watcher keeps track of all PressurePolicies and turns them into a
+// configuration with one profile per policy.
+type Watcher struct {
+	client   dynamic.Interface
+	nodeName string
+	informer informers.GenericInformer
+	changed  chan struct{}
+}
+
+func NewWatcher(client dynamic.Interface, nodeName string) *Watcher {
+	w := &Watcher{
+		client:   client,
+		nodeName: nodeName,
+		informer: dynamicinformer.NewFilteredDynamicInformer(client, Resource, "", 10*time.Minute, cache.Indexers{}, nil),
+		changed:  make(chan struct{}, 1),
+	}
+
+	notify := func() {
+		select {
+		case w.changed <- struct{}{}:
+		default:
+		}
+	}
+	w.informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { notify() },
+		UpdateFunc: func(old, new interface{}) {
+			// the generation only changes with the spec, which skips resyncs
+			// and changes to the status or metadata of a policy
+			if generation(old) != generation(new) {
+				notify()
+			}
+		},
+		DeleteFunc: func(interface{}) { notify() },
+	})
+
+	return w
+}
+
+func generation(obj interface{}) int64 {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return 0
+	}
+	return u.GetGeneration()
+}
+
+// Start starts watching and waits for the initial list of policies.
+func (w *Watcher) Start(closeChan chan struct{}) error {
+	go w.informer.Informer().Run(closeChan)
+
+	if !cache.WaitForCacheSync(closeChan, w.informer.Informer().HasSynced) {
+		return fmt.Errorf("could not sync %s", Resource.String())
+	}
+
+	return nil
+}
+
+// Synced reports whether the initial list of policies was received.
+func (w *Watcher) Synced() bool {
+	return w.informer.Informer().HasSynced()
+}
+
+// Config returns the default configuration with one profile per valid
+// policy, ordered by priority. Invalid policies are logged and skipped.
+func (w *Watcher) Config() (config.Config, error) {
+	objs, err := w.informer.Lister().List(everything)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	var policies []PressurePolicy
+	var profiles []config.Profile
+	base := config.Default()
+
+	for _, obj := range objs {
+		u, ok := obj.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+
+		p, profile, err := toProfile(u)
+		if err != nil {
+			glog.Errorf("ignoring invalid pressure policy %s: %s", u.GetName(), err.Error())
+			continue
+		}
+
+		resolved, err := base.WithProfile(&profile)
+		if err == nil {
+			err = resolved.Validate()
+		}
+		if err != nil {
+			glog.Errorf("ignoring invalid pressure policy %s: %s", u.GetName(), err.Error())
+			continue
+		}
+
+		policies = append(policies, p)
+		profiles = append(profiles, profile)
+	}
+
+	sort.Sort(byPriority{policies, profiles})
+
+	base.Profiles = profiles
+	return base, nil
+}
+
+// Run sends a new configuration whenever a policy changes.
+func (w *Watcher) Run(closeChan chan struct{}) <-chan config.Config {
+	configs := make(chan config.Config)
+
+	go func() {
+		defer close(configs)
+
+		for {
+			select {
+			case <-w.changed:
+				c, err := w.Config()
+				if err != nil {
+					glog.Errorf("could not list pressure policies: %s", err.Error())
+					continue
+				}
+
+				select {
+				case configs <- c:
+				case <-closeChan:
+					return
+				}
+			case <-closeChan:
+				return
+			}
+		}
+	}()
+
+	return configs
+}
+
+// toProfile splits a policy into its typed fields and the configuration
+// overrides, which are the remaining spec fields.
+func toProfile(u *unstructured.Unstructured) (PressurePolicy, config.Profile, error) {
+	var p PressurePolicy
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &p); err != nil {
+		return p, config.Profile{}, err
+	}
+	if err := validateSelector(p.Spec.NodeSelector); err != nil {
+		return p, config.Profile{}, err
+	}
+
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return p, config.Profile{}, err
+	}
+	delete(spec, "nodeSelector")
+	delete(spec, "priority")
+
+	overrides, err := json.Marshal(spec)
+	if err != nil {
+		return p, config.Profile{}, err
+	}
+
+	return p, config.Profile{
+		Name:         p.Name,
+		NodeSelector: p.Spec.NodeSelector,
+		Overrides:    overrides,
+	}, nil
+}
+
+type byPriority struct {
+	policies []PressurePolicy
+	profiles []config.Profile
+}
+
+func (s byPriority) Len() int {
+	return len(s.policies)
+}
+
+func (s byPriority) Less(i, j int) bool {
+	if s.policies[i].Spec.Priority != s.policies[j].Spec.Priority {
+		return s.policies[i].Spec.Priority > s.policies[j].Spec.Priority
+	}
+	return s.policies[i].Name < s.policies[j].Name
+}
+
+func (s byPriority) Swap(i, j int) {
+	s.policies[i], s.policies[j] = s.policies[j], s.policies[i]
+	s.profiles[i], s.profiles[j] = s.profiles[j], s.profiles[i]
+}
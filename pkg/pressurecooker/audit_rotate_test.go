@@ -0,0 +1,53 @@
+package pressurecooker
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileRotationFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	// a non-empty directory in place of the backup makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatalf("could not open file: %s", err.Error())
+	}
+	defer r.Close()
+
+	if _, err := r.Write([]byte("abc\n")); err != nil {
+		t.Fatalf("first write failed: %s", err.Error())
+	}
+	if _, err := r.Write([]byte("def\n")); err == nil {
+		t.Fatalf("expected the rotation to fail")
+	}
+
+	// once the backup can be written, rotation and writes must recover
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("ghi\n")); err != nil {
+		t.Fatalf("write after a failed rotation failed: %s", err.Error())
+	}
+
+	for file, want := range map[string]string{path: "ghi\n", path + ".1": "abc\n"} {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("could not read %s: %s", file, err.Error())
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(file), data, want)
+		}
+	}
+}
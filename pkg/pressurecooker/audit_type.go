@@ -0,0 +1,163 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// AuditSchemaVersion is written with every audit entry. Fields are only
+// ever added within a version.
+const AuditSchemaVersion = 1
+
+type AuditEntryType string
+
+const (
+	// AuditState is written when the load crosses the taint threshold.
+	AuditState AuditEntryType = "state"
+	// AuditSwitch is written when an action gets enabled or disabled.
+	AuditSwitch  AuditEntryType = "switch"
+	AuditTaint   AuditEntryType = "taint"
+	AuditUntaint AuditEntryType = "untaint"
+	// AuditRanking holds all eviction candidates with their scores.
+	AuditRanking AuditEntryType = "ranking"
+	// AuditEviction holds the outcome of an eviction or resize.
+	AuditEviction AuditEntryType = "eviction"
+)
+
+// outcomes of AuditEviction entries
+const (
+	OutcomeSuccess         = "success"
+	OutcomeDryRun          = "dry-run"
+	OutcomeError           = "error"
+	OutcomePDBRejected     = "pdb-rejected"
+	OutcomeNotFound        = "not-found"
+	OutcomeNoCandidate     = "no-candidate"
+	OutcomeBudgetExhausted = "budget-exhausted"
+	OutcomeDisruptionLimit = "disruption-limit"
+)
+
+// AuditEntry is a single line of the audit log. Fields that do not apply to
+// the entry type are omitted.
+type AuditEntry struct {
+	Version   int            `json:"version"`
+	Time      time.Time      `json:"time"`
+	Node      string         `json:"node"`
+	Type      AuditEntryType `json:"type"`
+	DryRun    bool           `json:"dryRun"`
+	Load      *Load          `json:"load,omitempty"`
+	Threshold float64        `json:"threshold,omitempty"`
+	// "high" or "low" for AuditState, "enabled" or "disabled" for AuditSwitch
+	State      string            `json:"state,omitempty"`
+	Reason     string            `json:"reason,omitempty"`
+	Candidates []RankedCandidate `json:"candidates,omitempty"`
+	// the selection mode used for AuditRanking
+	SelectionMode SelectionMode `json:"selectionMode,omitempty"`
+	Pod           string        `json:"pod,omitempty"`
+	// "taint" or "evict" for AuditSwitch, the EvictAction for AuditEviction
+	Action  string `json:"action,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+	// links the entries of one pressure episode, see Recorder
+	Incident string `json:"incident,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RankedCandidate is a scored eviction candidate, as written to the audit
+// log and returned by Explain.
+type RankedCandidate struct {
+	Pod      string         `json:"pod"`
+	Score    int            `json:"score"`
+	Scores   map[string]int `json:"scores"`
+	Excluded bool           `json:"excluded"`
+	// why the pod is excluded, if it is
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// AuditLog writes AuditEntry values as JSON lines. A nil *AuditLog discards
+// all entries.
+type AuditLog struct {
+	nodeName string
+	w        io.Writer
+	mu       sync.Mutex
+	clock    clock.Clock
+}
+
+func NewAuditLog(nodeName string, writers ...io.Writer) *AuditLog {
+	return &AuditLog{
+		nodeName: nodeName,
+		w:        io.MultiWriter(writers...),
+		clock:    clock.RealClock{},
+	}
+}
+
+// SetClock replaces the clock used for the time of the entries. It must be
+// called before the audit log is used.
+func (a *AuditLog) SetClock(c clock.Clock) {
+	if a == nil {
+		return
+	}
+
+	a.clock = c
+}
+
+// Log completes e with the version, time and node and writes it. Write
+// errors are logged, but never stop the controller.
+func (a *AuditLog) Log(e AuditEntry) {
+	if a == nil {
+		return
+	}
+
+	e.Version = AuditSchemaVersion
+	e.Time = a.clock.Now().UTC()
+	e.Node = a.nodeName
+
+	line, err := json.Marshal(&e)
+	if err != nil {
+		glog.Errorf("could not encode audit entry: %s", err.Error())
+		return
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, err := a.w.Write(line); err != nil {
+		glog.Errorf("could not write audit entry: %s", err.Error())
+	}
+}
+
+func auditLoad(evt ThresholdEvent) *Load {
+	if evt.Load == (Load{}) {
+		return nil
+	}
+
+	load := evt.Load
+	return &load
+}
+
+func rankedCandidates(s PodCandidateSet) []RankedCandidate {
+	candidates := make([]RankedCandidate, len(s))
+	for i := range s {
+		candidates[i] = RankedCandidate{
+			Pod:      s[i].Pod.Namespace + "/" + s[i].Pod.Name,
+			Score:    s[i].Score,
+			Scores:   s[i].Scores,
+			Excluded: s[i].Excluded(),
+			Reasons:  s[i].Reasons,
+		}
+	}
+
+	return candidates
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,177 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/golang/glog"
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// bucketCapacity is one minute's worth of tokens, but at least one token,
+// so rates below one per minute can still fill up to a whole eviction.
+func bucketCapacity(perMinute float64) float64 {
+	return math.Max(perMinute, 1)
+}
+
+// refill adds the tokens accumulated since the last update, up to the
+// capacity of the bucket, and reports whether at least one token is
+// available.
+func (b *tokenBucket) refill(now time.Time, perMinute float64) bool {
+	capacity := bucketCapacity(perMinute)
+
+	if updated, err := time.Parse(time.RFC3339Nano, b.Updated); err == nil {
+		b.Tokens += now.Sub(updated).Minutes() * perMinute
+	} else {
+		b.Tokens = capacity
+	}
+
+	if b.Tokens > capacity {
+		b.Tokens = capacity
+	}
+	b.Updated = now.Format(time.RFC3339Nano)
+
+	return b.Tokens >= 1
+}
+
+// retryAt returns when the bucket holds a whole token again.
+func (b *tokenBucket) retryAt(now time.Time, perMinute float64) time.Time {
+	return now.Add(time.Duration((1 - b.Tokens) / perMinute * float64(time.Minute)))
+}
+
+func (b *EvictionBudget) decode(lease *coordinationv1beta1.Lease) budgetState {
+	var state budgetState
+	if raw, ok := lease.Annotations[BudgetAnnotation]; ok {
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			glog.Warningf("resetting unreadable eviction budget on lease %s/%s: %s", b.namespace, b.name, err.Error())
+			state = budgetState{}
+		}
+	}
+	if state.Namespaces == nil {
+		state.Namespaces = make(map[string]tokenBucket)
+	}
+
+	return state
+}
+
+// store writes state to the lease. Full buckets of namespaces other than
+// namespace carry no information and are dropped to keep the lease small.
+func (b *EvictionBudget) store(lease *coordinationv1beta1.Lease, state budgetState, namespace string, now time.Time) error {
+	for ns, bucket := range state.Namespaces {
+		if ns != namespace && bucket.refill(now, b.namespacePerMinute) && bucket.Tokens >= bucketCapacity(b.namespacePerMinute) {
+			delete(state.Namespaces, ns)
+		}
+	}
+
+	raw, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+
+	leaseCopy := lease.DeepCopy()
+	if leaseCopy.Annotations == nil {
+		leaseCopy.Annotations = make(map[string]string)
+	}
+	leaseCopy.Annotations[BudgetAnnotation] = string(raw)
+	leaseCopy.Spec.HolderIdentity = &b.holder
+	renewTime := metav1.NewMicroTime(now)
+	leaseCopy.Spec.RenewTime = &renewTime
+
+	_, err = b.client.CoordinationV1beta1().Leases(b.namespace).Update(leaseCopy)
+	return err
+}
+
+// Take consumes one token from the cluster bucket and the bucket of
+// namespace. If either bucket is empty nothing is consumed and the exhausted
+// bucket is returned. With dryRun set the result is computed but nothing is
+// written.
+func (b *EvictionBudget) Take(namespace string, dryRun bool) (*BudgetExhausted, error) {
+	if b.clusterPerMinute <= 0 && b.namespacePerMinute <= 0 {
+		return nil, nil
+	}
+
+	var exhausted *BudgetExhausted
+
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		exhausted = nil
+		lease, err := getOrCreateLease(b.client, b.namespace, b.name)
+		if err != nil {
+			return err
+		}
+
+		state := b.decode(lease)
+		now := b.clock.Now()
+
+		if b.clusterPerMinute > 0 {
+			if !state.Cluster.refill(now, b.clusterPerMinute) {
+				glog.Infof("cluster eviction budget exhausted (%.2f tokens)", state.Cluster.Tokens)
+				exhausted = &BudgetExhausted{Until: state.Cluster.retryAt(now, b.clusterPerMinute)}
+				return nil
+			}
+		}
+
+		var nsBucket tokenBucket
+		if b.namespacePerMinute > 0 {
+			nsBucket = state.Namespaces[namespace]
+			if !nsBucket.refill(now, b.namespacePerMinute) {
+				glog.Infof("eviction budget for namespace %s exhausted (%.2f tokens)", namespace, nsBucket.Tokens)
+				exhausted = &BudgetExhausted{Namespace: namespace, Until: nsBucket.retryAt(now, b.namespacePerMinute)}
+				return nil
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+
+		if b.clusterPerMinute > 0 {
+			state.Cluster.Tokens--
+		}
+		if b.namespacePerMinute > 0 {
+			nsBucket.Tokens--
+			state.Namespaces[namespace] = nsBucket
+		}
+
+		return b.store(lease, state, namespace, now)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exhausted, nil
+}
+
+// Return gives back the token taken for namespace by Take, after the
+// eviction it was taken for failed.
+func (b *EvictionBudget) Return(namespace string) error {
+	if b.clusterPerMinute <= 0 && b.namespacePerMinute <= 0 {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := getOrCreateLease(b.client, b.namespace, b.name)
+		if err != nil {
+			return err
+		}
+
+		state := b.decode(lease)
+		now := b.clock.Now()
+
+		if b.clusterPerMinute > 0 {
+			state.Cluster.refill(now, b.clusterPerMinute)
+			state.Cluster.Tokens = math.Min(state.Cluster.Tokens+1, bucketCapacity(b.clusterPerMinute))
+		}
+		if b.namespacePerMinute > 0 {
+			nsBucket := state.Namespaces[namespace]
+			nsBucket.refill(now, b.namespacePerMinute)
+			nsBucket.Tokens = math.Min(nsBucket.Tokens+1, bucketCapacity(b.namespacePerMinute))
+			state.Namespaces[namespace] = nsBucket
+		}
+
+		return b.store(lease, state, namespace, now)
+	})
+}
@@ -0,0 +1,109 @@
+package pressurecooker
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestEvictionBudgetTake(t *testing.T) {
+	tests := []struct {
+		name      string
+		perMinute float64
+		// interval between two attempts
+		interval time.Duration
+		attempts int
+		taken    int
+	}{
+		{
+			name:      "one per minute",
+			perMinute: 1,
+			interval:  15 * time.Second,
+			attempts:  9,
+			taken:     3,
+		},
+		{
+			name:      "burst of one minute",
+			perMinute: 3,
+			interval:  time.Second,
+			attempts:  5,
+			taken:     3,
+		},
+		{
+			name:      "below one per minute",
+			perMinute: 0.2,
+			interval:  time.Minute,
+			attempts:  11,
+			taken:     3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clk := clock.NewFakeClock(time.Date(2020, time.March, 12, 3, 0, 0, 0, time.UTC))
+			b := NewEvictionBudget(fake.NewSimpleClientset(), "kube-system", "budget", "node-1", test.perMinute, 0)
+			b.clock = clk
+
+			taken := 0
+			for i := 0; i < test.attempts; i++ {
+				exhausted, err := b.Take("default", false)
+				if err != nil {
+					t.Fatalf("could not take a token: %s", err.Error())
+				}
+				if exhausted == nil {
+					taken++
+				}
+				clk.Step(test.interval)
+			}
+
+			if taken != test.taken {
+				t.Errorf("expected %d tokens to be taken, got %d", test.taken, taken)
+			}
+		})
+	}
+}
+
+func TestEvictionBudgetExhausted(t *testing.T) {
+	start := time.Date(2020, time.March, 12, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		cluster   float64
+		namespace float64
+		exhausted BudgetExhausted
+	}{
+		{
+			name:      "cluster",
+			cluster:   0.5,
+			namespace: 10,
+			exhausted: BudgetExhausted{Until: start.Add(2 * time.Minute)},
+		},
+		{
+			name:      "namespace",
+			cluster:   10,
+			namespace: 0.25,
+			exhausted: BudgetExhausted{Namespace: "default", Until: start.Add(4 * time.Minute)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewEvictionBudget(fake.NewSimpleClientset(), "kube-system", "budget", "node-1", test.cluster, test.namespace)
+			b.clock = clock.NewFakeClock(start)
+
+			if exhausted, err := b.Take("default", false); err != nil || exhausted != nil {
+				t.Fatalf("expected the first token to be taken, got %v, %v", exhausted, err)
+			}
+
+			exhausted, err := b.Take("default", false)
+			if err != nil {
+				t.Fatalf("could not take a token: %s", err.Error())
+			}
+			if exhausted == nil || *exhausted != test.exhausted {
+				t.Errorf("expected %+v, got %+v", test.exhausted, exhausted)
+			}
+		})
+	}
+}
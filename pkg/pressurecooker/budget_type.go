@@ -0,0 +1,69 @@
+package pressurecooker
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BudgetAnnotation holds the token bucket state on the budget Lease.
+const BudgetAnnotation = "pressurecooker/eviction-budget"
+
+// EvictionBudget is a cluster-wide token bucket limiting the rate of
+// evictions across all nodes. Its state is stored on a shared Lease and
+// updated with optimistic concurrency.
+type EvictionBudget struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	holder    string
+
+	// refill rates in evictions per minute; 0 means unlimited
+	clusterPerMinute   float64
+	namespacePerMinute float64
+
+	// set by Evicter.SetEvictionBudget
+	clock clock.Clock
+}
+
+// BudgetExhausted describes the bucket of an EvictionBudget that ran out.
+type BudgetExhausted struct {
+	// Namespace is empty if the cluster bucket ran out
+	Namespace string
+	// Until is when the bucket holds a whole token again
+	Until time.Time
+}
+
+func (x *BudgetExhausted) String() string {
+	if x.Namespace == "" {
+		return "the cluster eviction budget"
+	}
+
+	return fmt.Sprintf("the eviction budget of namespace %s", x.Namespace)
+}
+
+type tokenBucket struct {
+	Tokens  float64 `json:"tokens"`
+	Updated string  `json:"updated"`
+}
+
+type budgetState struct {
+	Cluster    tokenBucket            `json:"cluster"`
+	Namespaces map[string]tokenBucket `json:"namespaces,omitempty"`
+}
+
+// NewEvictionBudget creates a budget backed by the Lease namespace/name.
+// holder is recorded on the Lease as the last node to take a token.
+func NewEvictionBudget(client kubernetes.Interface, namespace, name, holder string, clusterPerMinute, namespacePerMinute float64) *EvictionBudget {
+	return &EvictionBudget{
+		client:             client,
+		namespace:          namespace,
+		name:               name,
+		holder:             holder,
+		clusterPerMinute:   clusterPerMinute,
+		namespacePerMinute: namespacePerMinute,
+		clock:              clock.RealClock{},
+	}
+}
@@ -0,0 +1,115 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Load reads the current eviction history from the Lease.
+func (l *DisruptionLimiter) Load() (DisruptionHistory, error) {
+	lease, err := getOrCreateLease(l.client, l.namespace, l.name)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.decode(lease.Annotations[DisruptionsAnnotation]), nil
+}
+
+func (l *DisruptionLimiter) decode(raw string) DisruptionHistory {
+	history := make(DisruptionHistory)
+	if raw == "" {
+		return history
+	}
+
+	if err := json.Unmarshal([]byte(raw), &history); err != nil {
+		glog.Warningf("resetting unreadable disruption history on lease %s/%s: %s", l.namespace, l.name, err.Error())
+		return make(DisruptionHistory)
+	}
+
+	return history
+}
+
+// Allows reports whether another pod of the same owner and namespace may be
+// evicted according to history.
+func (l *DisruptionLimiter) Allows(history DisruptionHistory, pod *v1.Pod) bool {
+	since := l.clock.Now().Add(-l.window)
+
+	if key := ownerKey(pod); l.maxPerOwner > 0 && key != "" && history.count(key, since) >= l.maxPerOwner {
+		return false
+	}
+
+	if l.maxPerNamespace > 0 && history.count(namespaceKey(pod), since) >= l.maxPerNamespace {
+		return false
+	}
+
+	return true
+}
+
+// Check reloads the history and reports whether pod may be evicted, in case
+// another node used up the limit since the candidates were ranked.
+func (l *DisruptionLimiter) Check(pod *v1.Pod) (bool, error) {
+	history, err := l.Load()
+	if err != nil {
+		return false, err
+	}
+
+	return l.Allows(history, pod), nil
+}
+
+// Record adds the eviction of pod to the shared history. It is called after
+// the eviction succeeded, so it does not check the limits again. With dryRun
+// set nothing is written.
+func (l *DisruptionLimiter) Record(pod *v1.Pod, dryRun bool) error {
+	if dryRun {
+		return nil
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		lease, err := getOrCreateLease(l.client, l.namespace, l.name)
+		if err != nil {
+			return err
+		}
+
+		history := l.decode(lease.Annotations[DisruptionsAnnotation])
+
+		now := l.clock.Now()
+		since := now.Add(-l.window)
+
+		for key, times := range history {
+			recent := times[:0]
+			for _, t := range times {
+				if t.After(since) {
+					recent = append(recent, t)
+				}
+			}
+			if len(recent) == 0 {
+				delete(history, key)
+			} else {
+				history[key] = recent
+			}
+		}
+
+		if key := ownerKey(pod); key != "" {
+			history[key] = append(history[key], now)
+		}
+		history[namespaceKey(pod)] = append(history[namespaceKey(pod)], now)
+
+		raw, err := json.Marshal(history)
+		if err != nil {
+			return err
+		}
+
+		leaseCopy := lease.DeepCopy()
+		if leaseCopy.Annotations == nil {
+			leaseCopy.Annotations = make(map[string]string)
+		}
+		leaseCopy.Annotations[DisruptionsAnnotation] = string(raw)
+		leaseCopy.Spec.HolderIdentity = &l.holder
+
+		_, err = l.client.CoordinationV1beta1().Leases(l.namespace).Update(leaseCopy)
+		return err
+	})
+}
@@ -0,0 +1,93 @@
+package pressurecooker
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DisruptionsAnnotation holds the recent eviction history on the disruption Lease.
+const DisruptionsAnnotation = "pressurecooker/disruptions"
+
+// DisruptionLimiter limits how many pods of the same owner workload and of
+// the same namespace may be evicted within a sliding window, across all
+// nodes. The history is stored on a shared Lease.
+type DisruptionLimiter struct {
+	client    kubernetes.Interface
+	namespace string
+	name      string
+	holder    string
+
+	// 0 means unlimited
+	maxPerOwner     int
+	maxPerNamespace int
+	window          time.Duration
+
+	// set by Evicter.SetDisruptionLimiter
+	clock clock.Clock
+}
+
+// DisruptionHistory maps owner and namespace keys to recent eviction times.
+type DisruptionHistory map[string][]time.Time
+
+func NewDisruptionLimiter(client kubernetes.Interface, namespace, name, holder string, maxPerOwner, maxPerNamespace int, window time.Duration) *DisruptionLimiter {
+	return &DisruptionLimiter{
+		client:          client,
+		namespace:       namespace,
+		name:            name,
+		holder:          holder,
+		maxPerOwner:     maxPerOwner,
+		maxPerNamespace: maxPerNamespace,
+		window:          window,
+		clock:           clock.RealClock{},
+	}
+}
+
+// ownerKey identifies the workload a pod belongs to. ReplicaSets created by
+// a Deployment are attributed to the Deployment, so that rollouts do not
+// reset the history.
+func ownerKey(pod *v1.Pod) string {
+	if owner := ownerName(pod); owner != "" {
+		return fmt.Sprintf("owner:%s/%s", pod.Namespace, owner)
+	}
+
+	return ""
+}
+
+// ownerName returns the workload of pod as kind/name, or an empty string
+// for standalone pods.
+func ownerName(pod *v1.Pod) string {
+	for _, o := range pod.OwnerReferences {
+		if o.Controller == nil || !*o.Controller {
+			continue
+		}
+
+		kind, name := o.Kind, o.Name
+		if hash, ok := pod.Labels["pod-template-hash"]; ok && kind == "ReplicaSet" && strings.HasSuffix(name, "-"+hash) {
+			kind, name = "Deployment", strings.TrimSuffix(name, "-"+hash)
+		}
+
+		return kind + "/" + name
+	}
+
+	return ""
+}
+
+func namespaceKey(pod *v1.Pod) string {
+	return "namespace:" + pod.Namespace
+}
+
+// count returns the number of evictions recorded for key since the given time.
+func (h DisruptionHistory) count(key string, since time.Time) int {
+	n := 0
+	for _, t := range h[key] {
+		if t.After(since) {
+			n++
+		}
+	}
+	return n
+}
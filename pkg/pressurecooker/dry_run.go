@@ -0,0 +1,12 @@
+package pressurecooker
+
+const dryRunPrefix = "[dry-run] "
+
+// dryRunTag returns the prefix for log lines and event messages describing
+// actions that were skipped because of dry-run mode.
+func dryRunTag(dryRun bool) string {
+	if dryRun {
+		return dryRunPrefix
+	}
+	return ""
+}
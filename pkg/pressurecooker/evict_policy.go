@@ -0,0 +1,61 @@
+package pressurecooker
+
+import "time"
+
+// ScoringWeights are the points a pod gets for each property that makes it
+// a good eviction candidate.
+type ScoringWeights struct {
+	BestEffort int `json:"bestEffort"`
+	Burstable  int `json:"burstable"`
+	Guaranteed int `json:"guaranteed"`
+	ReplicaSet int `json:"replicaSet"`
+	// multiplier for the logarithm of the pod age in seconds
+	Age int `json:"age"`
+	// points per 100% cpu usage of the pod's request, in cpu-usage mode
+	CPUUsage int `json:"cpuUsage"`
+}
+
+// Exclusions describe pods that are never evicted.
+type Exclusions struct {
+	Namespaces      []string `json:"namespaces"`
+	PriorityClasses []string `json:"priorityClasses"`
+	OwnerKinds      []string `json:"ownerKinds"`
+	// pods without owner will probably not be re-scheduled if evicted
+	StandalonePods bool `json:"standalonePods"`
+}
+
+// SelectionPolicy controls how PodCandidateSet ranks pods.
+type SelectionPolicy struct {
+	MinPodAge  time.Duration
+	Weights    ScoringWeights
+	Exclusions Exclusions
+}
+
+func DefaultScoringWeights() ScoringWeights {
+	return ScoringWeights{
+		BestEffort: 100,
+		Burstable:  100,
+		Guaranteed: 0,
+		ReplicaSet: 100,
+		Age:        1,
+		CPUUsage:   100,
+	}
+}
+
+func DefaultExclusions() Exclusions {
+	return Exclusions{
+		Namespaces:      []string{"kube-system"},
+		PriorityClasses: []string{"system-cluster-critical", "system-node-critical"},
+		OwnerKinds:      []string{"StatefulSet", "DaemonSet"},
+		StandalonePods:  true,
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,14 +1,39 @@
 package pressurecooker
 
 import (
+	"fmt"
 	"math"
 	"sort"
 	"time"
 
-	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
 )
 
+type SelectionMode string
+
+const (
+	// SelectionModeAge evicts old, well-behaved pods first.
+	SelectionModeAge SelectionMode = "age"
+	// SelectionModeCPUUsage evicts the pod using the most CPU relative to its request first.
+	SelectionModeCPUUsage SelectionMode = "cpu-usage"
+)
+
+func ParseSelectionMode(s string) (SelectionMode, error) {
+	switch m := SelectionMode(s); m {
+	case SelectionModeAge, SelectionModeCPUUsage:
+		return m, nil
+	}
+
+	return "", fmt.Errorf("unknown selection mode %q", s)
+}
+
+// pods without cpu requests are treated as if they requested this much
+const minCPURequestMillis = 10
+
+// upper bound for the usage score, so one noisy pod does not hide the
+// other rules in the ranking
+const maxCPUUsageScore = 1000
+
 type PodCandidateSet []PodCandidate
 
 func (s PodCandidateSet) Len() int {
@@ -28,6 +53,30 @@ func (s PodCandidateSet) Swap(i, j int) {
 type PodCandidate struct {
 	Pod   *v1.Pod
 	Score int
+	// Scores holds the part of Score added by each scoring rule
+	Scores map[string]int
+	// Reasons explains every penalty that excludes the pod
+	Reasons []string
+}
+
+func (c *PodCandidate) add(rule string, score int) {
+	if c.Scores == nil {
+		c.Scores = make(map[string]int)
+	}
+
+	c.Score += score
+	c.Scores[rule] += score
+}
+
+func (c *PodCandidate) exclude(rule string, score int, reason string) {
+	c.add(rule, score)
+	c.Reasons = append(c.Reasons, reason)
+}
+
+// Excluded reports whether the pod can not be selected. Exclusions do not
+// depend on the score, so no combination of weights can outweigh them.
+func (c *PodCandidate) Excluded() bool {
+	return len(c.Reasons) > 0
 }
 
 func PodCandidateSetFromPodList(l *v1.PodList) PodCandidateSet {
@@ -43,96 +92,144 @@ func PodCandidateSetFromPodList(l *v1.PodList) PodCandidateSet {
 	return s
 }
 
-func (s PodCandidateSet) scoreByQOSClass() {
+func (s PodCandidateSet) scoreByQOSClass(w ScoringWeights) {
 	for i := range s {
 		switch s[i].Pod.Status.QOSClass {
 		case v1.PodQOSBestEffort:
-			s[i].Score += 100
+			s[i].add("qos-class", w.BestEffort)
 		case v1.PodQOSBurstable:
-			s[i].Score += 100
+			s[i].add("qos-class", w.Burstable)
+		case v1.PodQOSGuaranteed:
+			s[i].add("qos-class", w.Guaranteed)
 		}
 	}
 }
 
-func (s PodCandidateSet) scoreByAge(minPodAge time.Duration) {
-	now := time.Now()
+func (s PodCandidateSet) scoreByMinAge(minPodAge time.Duration, now time.Time) {
+	for i, pod := range s {
+		if pod.Pod.Status.StartTime == nil {
+			s[i].exclude("min-age", -10000, "pod has not started")
+		} else if now.Sub(pod.Pod.Status.StartTime.Time) < minPodAge {
+			s[i].exclude("min-age", -10000, fmt.Sprintf("pod is younger than %s", minPodAge))
+		}
+	}
+}
+
+func (s PodCandidateSet) scoreByAge(minPodAge time.Duration, now time.Time, w ScoringWeights) {
 	for i, pod := range s {
 		if pod.Pod.Status.StartTime == nil {
-			s[i].Score -= 10000
 			continue
 		}
 		delta := now.Sub(pod.Pod.Status.StartTime.Time)
 		if delta < minPodAge {
-			s[i].Score -= 10000
 			continue
 		}
 		age := int64(delta / time.Second)
 		if age < 1 {
 			age = 1
 		}
-		s[i].Score += int(math.Floor(math.Log1p(float64(age))))
+		s[i].add("age", w.Age*int(math.Floor(math.Log1p(float64(age)))))
 	}
 }
 
-func (s PodCandidateSet) scoreByOwnerType() {
+func (s PodCandidateSet) scoreByOwnerType(w ScoringWeights, ex Exclusions) {
 	for i := range s {
-		// do not evict Pods without owner; these will probably not be re-scheduled if evicted
-		if len(s[i].Pod.OwnerReferences) == 0 {
-			s[i].Score -= 1000
+		if len(s[i].Pod.OwnerReferences) == 0 && ex.StandalonePods {
+			s[i].exclude("owner-type", -1000, "standalone pods are excluded")
 		}
 
 		for j := range s[i].Pod.OwnerReferences {
 			o := &s[i].Pod.OwnerReferences[j]
 
-			switch o.Kind {
-			case "ReplicaSet":
-				s[i].Score += 100
-			case "StatefulSet":
-				s[i].Score -= 10000
-			case "DaemonSet":
-				s[i].Score -= 10000
+			if containsString(ex.OwnerKinds, o.Kind) {
+				s[i].exclude("owner-type", -10000, fmt.Sprintf("owner kind %s is excluded", o.Kind))
+			} else if o.Kind == "ReplicaSet" {
+				s[i].add("owner-type", w.ReplicaSet)
 			}
 		}
 	}
 }
 
-func (s PodCandidateSet) scoreByCriticality() {
+func (s PodCandidateSet) scoreByCriticality(ex Exclusions) {
 	for i := range s {
-		if s[i].Pod.Namespace == "kube-system" {
-			s[i].Score -= 10000
+		if containsString(ex.Namespaces, s[i].Pod.Namespace) {
+			s[i].exclude("criticality", -10000, fmt.Sprintf("namespace %s is excluded", s[i].Pod.Namespace))
 		}
 
-		switch s[i].Pod.Spec.PriorityClassName {
-		case "system-cluster-critical":
-			s[i].Score -= 10000
-		case "system-node-critical":
-			s[i].Score -= 10000
+		if containsString(ex.PriorityClasses, s[i].Pod.Spec.PriorityClassName) {
+			s[i].exclude("criticality", -10000, fmt.Sprintf("priority class %s is excluded", s[i].Pod.Spec.PriorityClassName))
 		}
 
 		if _, ok := s[i].Pod.Annotations["scheduler.alpha.kubernetes.io/critical-pod"]; ok {
-			s[i].Score -= 10000
+			s[i].exclude("criticality", -10000, "pod is annotated as critical")
 		}
 	}
 }
 
-func (s PodCandidateSet) SelectPodForEviction(minPodAge time.Duration) *v1.Pod {
-	s.scoreByAge(minPodAge)
-	s.scoreByQOSClass()
-	s.scoreByOwnerType()
-	s.scoreByCriticality()
+func (s PodCandidateSet) scoreByCPUUsage(usage PodUsage, w ScoringWeights) {
+	for i := range s {
+		used, ok := usage[s[i].Pod.UID]
+		if !ok {
+			continue
+		}
 
-	sort.Stable(sort.Reverse(s))
+		var requested int64
+		for _, c := range s[i].Pod.Spec.Containers {
+			requested += c.Resources.Requests.Cpu().MilliValue()
+		}
+		if requested < minCPURequestMillis {
+			requested = minCPURequestMillis
+		}
+
+		score := int(math.Floor(float64(used) / float64(requested) * float64(w.CPUUsage)))
+		if score > maxCPUUsageScore {
+			score = maxCPUUsageScore
+		}
+		s[i].add("cpu-usage", score)
+	}
+}
 
+// scoreByDisruptionHistory excludes pods whose owner or namespace was
+// recently disrupted on any node.
+func (s PodCandidateSet) scoreByDisruptionHistory(l *DisruptionLimiter, history DisruptionHistory) {
 	for i := range s {
-		glog.Infof("eviction candidate: %s/%s (score of %d)", s[i].Pod.Namespace, s[i].Pod.Name, s[i].Score)
+		if !l.Allows(history, s[i].Pod) {
+			s[i].exclude("disruption-history", -10000, "owner or namespace was disrupted too often recently")
+		}
 	}
+}
+
+// SelectPodForEviction prefers the oldest pod. Pod ages are relative to now.
+func (s PodCandidateSet) SelectPodForEviction(p SelectionPolicy, now time.Time) *v1.Pod {
+	s.scoreByMinAge(p.MinPodAge, now)
+	s.scoreByAge(p.MinPodAge, now, p.Weights)
+	s.scoreByQOSClass(p.Weights)
+	s.scoreByOwnerType(p.Weights, p.Exclusions)
+	s.scoreByCriticality(p.Exclusions)
+
+	return s.selectHighestScore()
+}
+
+// SelectNoisyPodForEviction prefers the pod with the highest CPU usage to
+// request ratio over the oldest pod.
+func (s PodCandidateSet) SelectNoisyPodForEviction(p SelectionPolicy, usage PodUsage, now time.Time) *v1.Pod {
+	s.scoreByMinAge(p.MinPodAge, now)
+	s.scoreByCPUUsage(usage, p.Weights)
+	s.scoreByQOSClass(p.Weights)
+	s.scoreByOwnerType(p.Weights, p.Exclusions)
+	s.scoreByCriticality(p.Exclusions)
+
+	return s.selectHighestScore()
+}
+
+func (s PodCandidateSet) selectHighestScore() *v1.Pod {
+	sort.Stable(sort.Reverse(s))
 
 	for i := range s {
-		if s[i].Score < 0 {
+		if s[i].Excluded() {
 			continue
 		}
 
-		glog.Infof("selected candidate: %s/%s (score of %d)", s[i].Pod.Namespace, s[i].Pod.Name, s[i].Score)
 		return s[i].Pod
 	}
 
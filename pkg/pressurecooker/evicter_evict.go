@@ -1,42 +1,66 @@
 package pressurecooker
 
 import (
+	"context"
+	"fmt"
 	"time"
 
 	"github.com/golang/glog"
-	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/policy/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 )
 
-var (
-	prometheusNamespace = "pressurecooker"
-	podsEvictedTotal    = prometheus.NewGauge(prometheus.GaugeOpts{
-		Namespace: prometheusNamespace,
-		Name:      "pods_evicted_total",
-		Help:      "total number of pods evicted on this node",
-	})
-)
-
-func init() {
-	prometheus.MustRegister(podsEvictedTotal)
-}
-
 func (e *Evicter) CanEvict() bool {
+	if e.clock.Now().Before(e.budgetRetry) {
+		return false
+	}
+
 	if e.lastEviction.IsZero() {
 		return true
 	}
 
-	return time.Since(e.lastEviction) > e.backoff
+	return e.clock.Since(e.lastEviction) > e.backoff
 }
 
-func (e *Evicter) EvictPod(evt ThresholdEvent) (bool, error) {
+// BackoffUntil returns the time at which the backoff or the wait for an
+// exhausted eviction budget ends, which is in the past if eviction is
+// possible.
+func (e *Evicter) BackoffUntil() time.Time {
+	until := e.lastEviction.Add(e.backoff)
+	if e.budgetRetry.After(until) {
+		return e.budgetRetry
+	}
+
+	return until
+}
+
+func (e *Evicter) Threshold() float64 {
+	return e.threshold
+}
+
+// History returns the most recent evictions and resizes.
+func (e *Evicter) History() []EvictionRecord {
+	return append([]EvictionRecord(nil), e.history...)
+}
+
+func (e *Evicter) EvictPod(evt ThresholdEvent) (evicted bool, err error) {
 	if evt.Load.Load5Min < e.threshold {
 		return false, nil
 	}
 
+	ctx, span := tracer.Start(evt.context(), "evicter.evict_pod", trace.WithAttributes(loadAttributes(evt.Load, e.threshold)...))
+	span.SetAttributes(attribute.Bool("pressurecooker.dry_run", e.dryRun))
+	defer func() { finishSpan(span, err) }()
+
+	if e.disabled {
+		glog.Infof("eviction threshold exceeded; eviction is disabled")
+		return false, nil
+	}
+
 	if !e.CanEvict() {
 		glog.Infof("eviction threshold exceeded; still in back-off")
 		return false, nil
@@ -44,24 +68,93 @@ func (e *Evicter) EvictPod(evt ThresholdEvent) (bool, error) {
 
 	glog.Infof("searching for pod to evict")
 
-	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", e.nodeName)
-
-	podsOnNode, err := e.client.CoreV1().Pods("").List(metav1.ListOptions{
-		FieldSelector: fieldSelector.String(),
-	})
-
+	candidates, podToEvict, mode, err := e.rankCandidates(ctx)
 	if err != nil {
+		e.reportEviction(span, evt, nil, EvictActionEvict, OutcomeError, err)
 		return false, err
 	}
 
-	candidates := PodCandidateSetFromPodList(podsOnNode)
-	podToEvict := candidates.SelectPodForEviction(e.minPodAge)
+	for i := range candidates {
+		glog.Infof("eviction candidate: %s/%s (score of %d)", candidates[i].Pod.Namespace, candidates[i].Pod.Name, candidates[i].Score)
+	}
+	if podToEvict != nil {
+		glog.Infof("selected candidate: %s/%s", podToEvict.Namespace, podToEvict.Name)
+	}
+
+	eligible := 0
+	for i := range candidates {
+		if !candidates[i].Excluded() {
+			eligible++
+		}
+	}
+	evictionCandidates.WithLabelValues("all").Observe(float64(len(candidates)))
+	evictionCandidates.WithLabelValues("eligible").Observe(float64(eligible))
+	span.SetAttributes(candidateAttributes(candidates, podToEvict)...)
+
+	e.audit.Log(AuditEntry{
+		Type:          AuditRanking,
+		DryRun:        e.dryRun,
+		Load:          auditLoad(evt),
+		Threshold:     e.threshold,
+		Candidates:    rankedCandidates(candidates),
+		SelectionMode: mode,
+		Incident:      e.recorder.Incident(),
+	})
 
 	if podToEvict == nil {
-		e.recorder.Eventf(e.nodeRef, v1.EventTypeWarning, "NoPodToEvict", "wanted to evict Pod, but no suitable candidate found")
+		e.reportEviction(span, evt, nil, EvictActionEvict, OutcomeNoCandidate, nil)
+		e.recorder.NodeEventf(v1.EventTypeWarning, ReasonNoEvictionCandidate, "wanted to evict Pod, but no suitable candidate found")
 		return false, nil
 	}
 
+	if e.action == EvictActionResize {
+		// makes the resize span a child of this one
+		evt.SpanContext = span.SpanContext()
+		resized, err := e.ResizePod(podToEvict, evt)
+		if err == nil && resized {
+			e.reportEviction(span, evt, podToEvict, EvictActionResize, outcome(nil, e.dryRun), nil)
+			return true, nil
+		}
+		if err != nil {
+			e.reportEviction(span, evt, podToEvict, EvictActionResize, outcome(err, false), err)
+			if !resizeUnsupported(err) {
+				return false, err
+			}
+			glog.Warningf("could not resize pod %s/%s, falling back to eviction: %s", podToEvict.Namespace, podToEvict.Name, err.Error())
+		}
+	}
+
+	// both limits are checked before the token is taken, so a pod denied by
+	// the disruption limit does not use up the budget
+	if e.disruptions != nil {
+		ok, err := e.disruptions.Check(podToEvict)
+		if err != nil {
+			e.reportEviction(span, evt, podToEvict, EvictActionEvict, OutcomeError, err)
+			return false, err
+		}
+		if !ok {
+			e.reportEviction(span, evt, podToEvict, EvictActionEvict, OutcomeDisruptionLimit, nil)
+			e.recorder.NodeEventf(v1.EventTypeNormal, ReasonDisruptionLimitReached, "%swanted to evict %s/%s, but its owner or namespace was disrupted too often recently", dryRunTag(e.dryRun), podToEvict.Namespace, podToEvict.Name)
+			return false, nil
+		}
+	}
+
+	if e.budget != nil {
+		exhausted, err := e.budget.Take(podToEvict.Namespace, e.dryRun)
+		if err != nil {
+			e.reportEviction(span, evt, podToEvict, EvictActionEvict, OutcomeError, err)
+			return false, err
+		}
+		if exhausted != nil {
+			// the same pod would be selected again, so there is no point in
+			// ranking the pods before the budget refills
+			e.budgetRetry = exhausted.Until
+			e.reportEviction(span, evt, podToEvict, EvictActionEvict, OutcomeBudgetExhausted, nil)
+			e.recorder.NodeEventf(v1.EventTypeNormal, ReasonEvictionBudgetExhausted, "%swanted to evict %s/%s, but %s is exhausted until %s", dryRunTag(e.dryRun), podToEvict.Namespace, podToEvict.Name, exhausted.String(), exhausted.Until.Format(time.RFC3339))
+			return false, nil
+		}
+	}
+
 	eviction := v1beta1.Eviction{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      podToEvict.ObjectMeta.Name,
@@ -69,15 +162,113 @@ func (e *Evicter) EvictPod(evt ThresholdEvent) (bool, error) {
 		},
 	}
 
-	podsEvictedTotal.Inc()
+	tag := dryRunTag(e.dryRun)
 
-	glog.Infof("eviction: %+v", eviction)
+	glog.Infof("%seviction: %+v", tag, eviction)
 
-	e.lastEviction = time.Now()
+	e.recorder.Eventf(podToEvict, v1.EventTypeWarning, ReasonPodEvicted, "%sevicting pod due to high cpu pressure on node: %s", tag, evt.String())
+	e.recorder.NodeEventf(v1.EventTypeWarning, ReasonPodEvicted, "%sevicting pod due to high cpu pressure on node: %s", tag, evt.String())
 
-	e.recorder.Eventf(podToEvict, v1.EventTypeWarning, "EvictHighLoad", "evicting pod due to high cpu pressure on node: %s", evt.String())
-	e.recorder.Eventf(e.nodeRef, v1.EventTypeWarning, "EvictHighLoad", "evicting pod due to high cpu pressure on node: %s", evt.String())
+	if !e.dryRun {
+		_, apiSpan := tracer.Start(ctx, "kubernetes.evict")
+		err = e.client.CoreV1().Pods(podToEvict.Namespace).Evict(&eviction)
+		finishSpan(apiSpan, err)
+	}
+
+	if err != nil && e.budget != nil {
+		if returnErr := e.budget.Return(podToEvict.Namespace); returnErr != nil {
+			glog.Errorf("could not return eviction budget token for %s/%s: %s", podToEvict.Namespace, podToEvict.Name, returnErr.Error())
+		}
+	}
+	// rejected evictions, e.g. by a PodDisruptionBudget, neither count
+	// towards the backoff nor show up in the history
+	if err == nil {
+		e.recordEviction(podToEvict, EvictActionEvict)
+	}
+	if err == nil && e.disruptions != nil {
+		if recordErr := e.disruptions.Record(podToEvict, e.dryRun); recordErr != nil {
+			glog.Errorf("could not record disruption of %s/%s: %s", podToEvict.Namespace, podToEvict.Name, recordErr.Error())
+		}
+	}
+
+	result := outcome(err, e.dryRun)
+	podsEvictedTotal.WithLabelValues(podToEvict.Namespace, ownerKindLabel(podToEvict), result).Inc()
+	e.reportEviction(span, evt, podToEvict, EvictActionEvict, result, err)
+	if result == OutcomeSuccess {
+		e.notify(NotificationEviction, evt, podToEvict, ReasonPodEvicted)
+	}
 
-	err = e.client.CoreV1().Pods(podToEvict.Namespace).Evict(&eviction)
 	return true, err
 }
+
+// rankCandidates scores all pods on the node. The candidates are sorted by
+// descending score; the selected pod is nil if all of them are excluded. The
+// mode is the selection mode actually used, which differs from the
+// configured one if the cpu usage could not be read.
+func (e *Evicter) rankCandidates(ctx context.Context) (candidates PodCandidateSet, selected *v1.Pod, mode SelectionMode, err error) {
+	_, span := tracer.Start(ctx, "evicter.rank_candidates")
+	defer func() { finishSpan(span, err) }()
+
+	e.mu.Lock()
+	policy, mode, usageGetter, fallback, disruptions := e.policy, e.selectionMode, e.usageGetter, e.selectionFallback, e.disruptions
+	now := e.clock.Now()
+	e.mu.Unlock()
+
+	fieldSelector := fields.OneTermEqualSelector("spec.nodeName", e.nodeName)
+
+	podsOnNode, err := e.client.CoreV1().Pods("").List(metav1.ListOptions{
+		FieldSelector: fieldSelector.String(),
+	})
+
+	if err != nil {
+		return nil, nil, mode, err
+	}
+
+	candidates = PodCandidateSetFromPodList(podsOnNode)
+
+	if disruptions != nil {
+		history, err := disruptions.Load()
+		if err != nil {
+			return nil, nil, mode, err
+		}
+		candidates.scoreByDisruptionHistory(disruptions, history)
+	}
+
+	switch mode {
+	case SelectionModeCPUUsage:
+		usage, err := usageGetter.GetPodUsage()
+		if err == nil {
+			return candidates, candidates.SelectNoisyPodForEviction(policy, usage, now), mode, nil
+		}
+		if !fallback {
+			return nil, nil, mode, fmt.Errorf("could not get pod cpu usage: %s", err.Error())
+		}
+		glog.Warningf("could not get pod cpu usage, selecting pods by age instead: %s", err.Error())
+		return candidates, candidates.SelectPodForEviction(policy, now), SelectionModeAge, nil
+	default:
+		return candidates, candidates.SelectPodForEviction(policy, now), mode, nil
+	}
+}
+
+func (e *Evicter) reportEviction(span trace.Span, evt ThresholdEvent, pod *v1.Pod, action EvictAction, outcome string, err error) {
+	entry := AuditEntry{
+		Type:      AuditEviction,
+		DryRun:    e.dryRun,
+		Load:      auditLoad(evt),
+		Threshold: e.threshold,
+		Action:    string(action),
+		Outcome:   outcome,
+		Incident:  e.recorder.Incident(),
+		Error:     errorString(err),
+	}
+	if pod != nil {
+		entry.Pod = pod.Namespace + "/" + pod.Name
+		span.SetAttributes(attribute.String("pressurecooker.pod", entry.Pod))
+	}
+	span.SetAttributes(
+		attribute.String("pressurecooker.action", string(action)),
+		attribute.String("pressurecooker.outcome", outcome),
+	)
+
+	e.audit.Log(entry)
+}
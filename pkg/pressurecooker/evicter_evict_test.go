@@ -0,0 +1,85 @@
+package pressurecooker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type failingUsageGetter struct{}
+
+func (failingUsageGetter) GetPodUsage() (PodUsage, error) {
+	return nil, errors.New("nodes/proxy is forbidden")
+}
+
+func TestEvicterUsageFallback(t *testing.T) {
+	now := time.Date(2020, time.March, 12, 3, 0, 0, 0, time.UTC)
+	controller := true
+	pod := func(name string, age time.Duration) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      name,
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1",
+					Kind:       "ReplicaSet",
+					Name:       name + "-rs",
+					Controller: &controller,
+				}},
+			},
+			Spec:   v1.PodSpec{NodeName: "node-1"},
+			Status: v1.PodStatus{Phase: v1.PodRunning, StartTime: &metav1.Time{Time: now.Add(-age)}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		fallback bool
+		selected string
+		mode     SelectionMode
+	}{
+		{name: "fallback to age", fallback: true, selected: "old", mode: SelectionModeAge},
+		{name: "no fallback"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(
+				&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+				pod("young", time.Hour),
+				pod("old", 48*time.Hour),
+			)
+			e, err := NewEvicter(client, nil, 50, "node-1", time.Minute, time.Minute)
+			if err != nil {
+				t.Fatalf("could not create evicter: %s", err.Error())
+			}
+			e.SetClock(clock.NewFakeClock(now))
+			if err := e.SetSelectionMode(SelectionModeCPUUsage, failingUsageGetter{}, tt.fallback); err != nil {
+				t.Fatalf("could not set selection mode: %s", err.Error())
+			}
+
+			_, selected, mode, err := e.rankCandidates(context.Background())
+			if !tt.fallback {
+				if err == nil {
+					t.Errorf("ranking without cpu usage succeeded without the fallback")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ranking failed without cpu usage: %s", err.Error())
+			}
+			if selected == nil || selected.Name != tt.selected {
+				t.Errorf("selected %v, want %s", selected, tt.selected)
+			}
+			if mode != tt.mode {
+				t.Errorf("mode = %s, want %s", mode, tt.mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package pressurecooker
+
+import "context"
+
+// Explanation is the ranking of the pods on the node as EvictPod would
+// compute it at the time of the call.
+type Explanation struct {
+	Node string `json:"node"`
+	// the mode used for this ranking, "age" if the cpu usage could not be
+	// read and the selection falls back to age
+	SelectionMode SelectionMode `json:"selectionMode"`
+	// the pod that would be evicted or resized next, empty if there is none
+	Selected   string            `json:"selected,omitempty"`
+	Candidates []RankedCandidate `json:"candidates"`
+}
+
+// Explain ranks the pods on the node without evicting any of them. It
+// ignores the threshold, the backoff and the eviction budget.
+func (e *Evicter) Explain() (*Explanation, error) {
+	candidates, pod, mode, err := e.rankCandidates(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	x := &Explanation{
+		Node:          e.nodeName,
+		SelectionMode: mode,
+		Candidates:    rankedCandidates(candidates),
+	}
+	if pod != nil {
+		x.Selected = pod.Namespace + "/" + pod.Name
+	}
+
+	return x, nil
+}
@@ -0,0 +1,147 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type EvictAction string
+
+const (
+	// EvictActionEvict evicts the selected pod.
+	EvictActionEvict EvictAction = "evict"
+	// EvictActionResize raises the cpu requests of the selected pod in place
+	// and only evicts if that is not possible.
+	EvictActionResize EvictAction = "resize"
+)
+
+func ParseEvictAction(s string) (EvictAction, error) {
+	switch a := EvictAction(s); a {
+	case EvictActionEvict, EvictActionResize:
+		return a, nil
+	}
+
+	return "", fmt.Errorf("unknown evict action %q", s)
+}
+
+type resizeContainer struct {
+	Name      string `json:"name"`
+	Resources struct {
+		Requests map[v1.ResourceName]resource.Quantity `json:"requests"`
+	} `json:"resources"`
+}
+
+type resizePatch struct {
+	Spec struct {
+		Containers []resizeContainer `json:"containers"`
+	} `json:"spec"`
+}
+
+// SetAction changes what happens to the selected pod. With
+// EvictActionResize its cpu requests are raised by factor instead of
+// evicting it, but never above maxCPU per container or above the
+// container's limit.
+func (e *Evicter) SetAction(action EvictAction, factor float64, maxCPU resource.Quantity) error {
+	if action == EvictActionResize && factor <= 1 {
+		return fmt.Errorf("resize factor must be greater than 1, got %v", factor)
+	}
+
+	e.action = action
+	e.resizeFactor = factor
+	e.resizeMaxCPU = maxCPU
+	return nil
+}
+
+// resizedContainers returns the containers of pod whose cpu request can be
+// raised, with the new requests set.
+func (e *Evicter) resizedContainers(pod *v1.Pod) []resizeContainer {
+	var containers []resizeContainer
+
+	for _, c := range pod.Spec.Containers {
+		current, ok := c.Resources.Requests[v1.ResourceCPU]
+		if !ok || current.IsZero() {
+			// adding a request would change the QoS class, which is not allowed
+			continue
+		}
+
+		upper := e.resizeMaxCPU.MilliValue()
+		if limit, ok := c.Resources.Limits[v1.ResourceCPU]; ok && limit.MilliValue() < upper {
+			upper = limit.MilliValue()
+		}
+
+		target := int64(math.Ceil(float64(current.MilliValue()) * e.resizeFactor))
+		if target > upper {
+			target = upper
+		}
+		if target <= current.MilliValue() {
+			continue
+		}
+
+		rc := resizeContainer{Name: c.Name}
+		rc.Resources.Requests = map[v1.ResourceName]resource.Quantity{
+			v1.ResourceCPU: *resource.NewMilliQuantity(target, resource.DecimalSI),
+		}
+		containers = append(containers, rc)
+	}
+
+	return containers
+}
+
+// ResizePod raises the cpu requests of pod through the resize subresource.
+// It returns false if none of the containers can be raised any further.
+func (e *Evicter) ResizePod(pod *v1.Pod, evt ThresholdEvent) (bool, error) {
+	containers := e.resizedContainers(pod)
+	if len(containers) == 0 {
+		glog.Infof("pod %s/%s can not be resized any further", pod.Namespace, pod.Name)
+		return false, nil
+	}
+
+	var patch resizePatch
+	patch.Spec.Containers = containers
+
+	data, err := json.Marshal(&patch)
+	if err != nil {
+		return false, err
+	}
+
+	tag := dryRunTag(e.dryRun)
+
+	glog.Infof("%sresize: %s/%s %s", tag, pod.Namespace, pod.Name, data)
+
+	if !e.dryRun {
+		_, span := tracer.Start(evt.context(), "kubernetes.resize")
+		_, err = e.client.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.StrategicMergePatchType, data, "resize")
+		finishSpan(span, err)
+	}
+
+	podsResizedTotal.WithLabelValues(pod.Namespace, ownerKindLabel(pod), outcome(err, e.dryRun)).Inc()
+
+	if err != nil {
+		return false, err
+	}
+
+	e.recordEviction(pod, EvictActionResize)
+
+	e.recorder.Eventf(pod, v1.EventTypeWarning, ReasonPodResized, "%sraising cpu requests due to high cpu pressure on node: %s", tag, evt.String())
+	e.recorder.NodeEventf(v1.EventTypeWarning, ReasonPodResized, "%sraising cpu requests of %s/%s due to high cpu pressure on node: %s", tag, pod.Namespace, pod.Name, evt.String())
+
+	if !e.dryRun {
+		e.notify(NotificationResize, evt, pod, ReasonPodResized)
+	}
+
+	return true, nil
+}
+
+// resizeUnsupported reports whether err means the cluster can not resize the
+// pod at all, in which case it is evicted instead. Other errors, e.g. a
+// timeout, are returned so the pod is not evicted by accident.
+func resizeUnsupported(err error) bool {
+	return errors.IsNotFound(err) || errors.IsMethodNotSupported(err) || errors.IsInvalid(err)
+}
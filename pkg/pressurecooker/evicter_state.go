@@ -0,0 +1,100 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// EvictionStateAnnotation holds the evicter state on the Node, so that the
+// backoff survives restarts.
+const EvictionStateAnnotation = "pressurecooker/eviction-state"
+
+// number of evictions kept in the persisted history
+const evictionHistoryLength = 10
+
+type EvictionRecord struct {
+	Time   time.Time   `json:"time"`
+	Pod    string      `json:"pod"`
+	Action EvictAction `json:"action"`
+}
+
+type evictionState struct {
+	LastEviction time.Time        `json:"lastEviction"`
+	History      []EvictionRecord `json:"history,omitempty"`
+}
+
+// loadState restores the last eviction time and history from the Node.
+func (e *Evicter) loadState() error {
+	node, err := e.client.CoreV1().Nodes().Get(e.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	raw, ok := node.Annotations[EvictionStateAnnotation]
+	if !ok {
+		return nil
+	}
+
+	var state evictionState
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		glog.Warningf("ignoring unreadable %s annotation on node %s: %s", EvictionStateAnnotation, e.nodeName, err.Error())
+		return nil
+	}
+
+	e.lastEviction = state.LastEviction
+	e.history = state.History
+
+	glog.Infof("restored eviction state: last eviction at %s, %d evictions in history", e.lastEviction, len(e.history))
+
+	return nil
+}
+
+// recordEviction starts the backoff and persists it on the Node. Failing to
+// persist is logged, but not treated as an error.
+func (e *Evicter) recordEviction(pod *v1.Pod, action EvictAction) {
+	now := e.clock.Now()
+
+	e.lastEviction = now
+	e.history = append(e.history, EvictionRecord{
+		Time:   now,
+		Pod:    pod.Namespace + "/" + pod.Name,
+		Action: action,
+	})
+	if len(e.history) > evictionHistoryLength {
+		e.history = e.history[len(e.history)-evictionHistoryLength:]
+	}
+
+	if e.dryRun {
+		return
+	}
+
+	raw, err := json.Marshal(&evictionState{
+		LastEviction: e.lastEviction,
+		History:      e.history,
+	})
+	if err != nil {
+		glog.Errorf("could not encode eviction state: %s", err.Error())
+		return
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				EvictionStateAnnotation: string(raw),
+			},
+		},
+	})
+	if err != nil {
+		glog.Errorf("could not encode eviction state patch: %s", err.Error())
+		return
+	}
+
+	if _, err := e.client.CoreV1().Nodes().Patch(e.nodeName, types.MergePatchType, patch); err != nil {
+		glog.Errorf("could not persist eviction state on node %s: %s", e.nodeName, err.Error())
+	}
+}
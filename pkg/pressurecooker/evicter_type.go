@@ -1,65 +1,187 @@
 package pressurecooker
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/client-go/tools/record"
 )
 
 type Evicter struct {
-	client       kubernetes.Interface
-	threshold    float64
-	nodeName     string
-	nodeRef      *v1.ObjectReference
-	recorder     record.EventRecorder
-	minPodAge    time.Duration
+	client    kubernetes.Interface
+	threshold float64
+	nodeName  string
+	recorder  *Recorder
+	// guards policy, the selection mode and disruptions, which
+	// Explain reads concurrently
+	mu           sync.Mutex
+	policy       SelectionPolicy
 	backoff      time.Duration
 	lastEviction time.Time
+	history      []EvictionRecord
+
+	selectionMode     SelectionMode
+	usageGetter       PodUsageGetter
+	selectionFallback bool
+
+	action       EvictAction
+	resizeFactor float64
+	resizeMaxCPU resource.Quantity
+
+	dryRun   bool
+	disabled bool
+
+	budget      *EvictionBudget
+	disruptions *DisruptionLimiter
+	// no eviction is attempted before the exhausted budget refills
+	budgetRetry time.Time
+
+	audit    *AuditLog
+	notifier Notifier
+	clock    clock.Clock
 }
 
-func NewEvicter(client kubernetes.Interface, threshold float64, nodeName string, backoff string, minPodAge string) (*Evicter, error) {
+func NewEvicter(client kubernetes.Interface, r *Recorder, threshold float64, nodeName string, backoff time.Duration, minPodAge time.Duration) (*Evicter, error) {
 	if threshold == 0 {
 		threshold = 50
 	}
 
-	backoffDuration, err := time.ParseDuration(backoff)
-	if err != nil {
-		return nil, err
+	e := &Evicter{
+		client:    client,
+		threshold: threshold,
+		nodeName:  nodeName,
+		recorder:  r,
+		backoff:   backoff,
+		policy: SelectionPolicy{
+			MinPodAge:  minPodAge,
+			Weights:    DefaultScoringWeights(),
+			Exclusions: DefaultExclusions(),
+		},
+
+		selectionMode: SelectionModeAge,
+		action:        EvictActionEvict,
+		clock:         clock.RealClock{},
 	}
 
-	minPodAgeDuration, err := time.ParseDuration(minPodAge)
-	if err != nil {
+	if err := e.loadState(); err != nil {
 		return nil, err
 	}
 
-	b := record.NewBroadcaster()
-	b.StartLogging(glog.Infof)
-	b.StartRecordingToSink(&typedv1.EventSinkImpl{
-		Interface: client.CoreV1().Events(""),
-	})
+	return e, nil
+}
+
+func (e *Evicter) SetThreshold(threshold float64) {
+	e.threshold = threshold
+}
+
+// SetTiming changes the backoff between evictions and the minimum age of
+// pods to be evicted.
+func (e *Evicter) SetTiming(backoff time.Duration, minPodAge time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.backoff = backoff
+	e.policy.MinPodAge = minPodAge
+}
 
-	r := b.NewRecorder(scheme.Scheme, v1.EventSource{Host: nodeName, Component: ComponentName + "/evicter"})
+// SetScoring replaces the scoring weights and exclusions used to rank pods.
+func (e *Evicter) SetScoring(w ScoringWeights, ex Exclusions) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 
-	nodeRef := &v1.ObjectReference{
-		Kind:      "Node",
-		Name:      nodeName,
-		UID:       types.UID(nodeName),
-		Namespace: "",
+	e.policy.Weights = w
+	e.policy.Exclusions = ex
+}
+
+// SetSelectionMode changes how eviction candidates are ranked. The usage
+// getter is only required for SelectionModeCPUUsage. With fallback, pods are
+// ranked by age if the usage can not be read.
+func (e *Evicter) SetSelectionMode(mode SelectionMode, usageGetter PodUsageGetter, fallback bool) error {
+	if mode == SelectionModeCPUUsage && usageGetter == nil {
+		return fmt.Errorf("selection mode %s requires a pod usage getter", mode)
 	}
 
-	return &Evicter{
-		client:    client,
-		threshold: threshold,
-		nodeName:  nodeName,
-		nodeRef:   nodeRef,
-		recorder:  r,
-		backoff:   backoffDuration,
-		minPodAge: minPodAgeDuration,
-	}, nil
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.selectionMode = mode
+	e.usageGetter = usageGetter
+	e.selectionFallback = fallback
+	return nil
+}
+
+// SetDryRun makes the evicter select pods and report what it would do
+// without actually evicting or resizing them.
+func (e *Evicter) SetDryRun(dryRun bool) {
+	e.dryRun = dryRun
+}
+
+// SetEvictionBudget limits evictions by a cluster-wide budget shared with
+// the evicters on other nodes.
+func (e *Evicter) SetEvictionBudget(b *EvictionBudget) {
+	if b != nil {
+		b.clock = e.clock
+	}
+	e.budget = b
+}
+
+// SetDisruptionLimiter skips pods whose owner or namespace was evicted too
+// often recently on any node.
+func (e *Evicter) SetDisruptionLimiter(l *DisruptionLimiter) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if l != nil {
+		l.clock = e.clock
+	}
+	e.disruptions = l
+}
+
+// SetClock replaces the clock used for the backoff, the pod age and by the
+// eviction budget and disruption limiter. It must be called before the
+// evicter is used.
+func (e *Evicter) SetClock(c clock.Clock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.clock = c
+	if e.budget != nil {
+		e.budget.clock = c
+	}
+	if e.disruptions != nil {
+		e.disruptions.clock = c
+	}
+}
+
+func (e *Evicter) SetEnabled(enabled bool) {
+	e.disabled = !enabled
+}
+
+// SetNotifier sends a notification to n for every evicted or resized pod.
+func (e *Evicter) SetNotifier(n Notifier) {
+	e.notifier = n
+}
+
+func (e *Evicter) notify(typ NotificationType, evt ThresholdEvent, pod *v1.Pod, reason string) {
+	notify(e.notifier, e.clock, Notification{
+		Node:      e.nodeName,
+		Type:      typ,
+		Level:     "warning",
+		Load:      evt.Load,
+		Threshold: evt.Threshold,
+		Pod:       pod.Namespace + "/" + pod.Name,
+		Namespace: pod.Namespace,
+		Owner:     ownerName(pod),
+		Reason:    reason,
+		Incident:  e.recorder.Incident(),
+	})
+}
+
+// SetAuditLog records candidate rankings and eviction outcomes to a.
+func (e *Evicter) SetAuditLog(a *AuditLog) {
+	e.audit = a
 }
@@ -0,0 +1,33 @@
+package pressurecooker
+
+import (
+	coordinationv1beta1 "k8s.io/api/coordination/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// getOrCreateLease returns the Lease namespace/name, creating it if needed.
+// Losing the race to create it is reported as a conflict, so that callers
+// inside retry.RetryOnConflict simply try again.
+func getOrCreateLease(client kubernetes.Interface, namespace, name string) (*coordinationv1beta1.Lease, error) {
+	lease, err := client.CoordinationV1beta1().Leases(namespace).Get(name, metav1.GetOptions{})
+	if err == nil {
+		return lease, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	lease, err = client.CoordinationV1beta1().Leases(namespace).Create(&coordinationv1beta1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	})
+	if errors.IsAlreadyExists(err) {
+		return nil, errors.NewConflict(coordinationv1beta1.Resource("leases"), name, err)
+	}
+
+	return lease, err
+}
@@ -7,10 +7,19 @@ import (
 )
 
 type Load struct {
-	Source   string
-	Smallest float64
-	Load1Min float64
-	Load5Min float64
+	Source   string  `json:"source"`
+	Smallest float64 `json:"smallest"`
+	Load1Min float64 `json:"load1m"`
+	Load5Min float64 `json:"load5m"`
+}
+
+// all load getters measure cpu load; used as the resource label
+const loadResource = "cpu"
+
+func exportLoad(l Load) {
+	loadValue.WithLabelValues(loadResource, l.Source, "smallest").Set(l.Smallest)
+	loadValue.WithLabelValues(loadResource, l.Source, "load1m").Set(l.Load1Min)
+	loadValue.WithLabelValues(loadResource, l.Source, "load5m").Set(l.Load5Min)
 }
 
 type LoadGetter interface {
@@ -50,7 +59,7 @@ func (g *LoadAvgLoadGetter) GetLoad() (Load, error) {
 	}
 
 	return Load{
-		Source:   "psi",
+		Source:   "loadavg",
 		Smallest: la.Load1,
 		Load1Min: la.Load1,
 		Load5Min: la.Load5,
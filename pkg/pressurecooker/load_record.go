@@ -0,0 +1,77 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// LoadTraceVersion is the version of the load trace format. Fields are only
+// added within a version.
+const LoadTraceVersion = 1
+
+// LoadSample is one line of a load trace. Load is nil if reading the load
+// failed, Error holds the reason.
+type LoadSample struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	Node    string    `json:"node,omitempty"`
+	Load    *Load     `json:"load,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// RecordingLoadGetter passes the samples of another LoadGetter through and
+// writes them to a load trace, one JSON object per line, so they can be
+// replayed with a ReplayLoadGetter.
+type RecordingLoadGetter struct {
+	getter   LoadGetter
+	nodeName string
+	clock    clock.Clock
+
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewRecordingLoadGetter(getter LoadGetter, nodeName string, w io.Writer) *RecordingLoadGetter {
+	return &RecordingLoadGetter{
+		getter:   getter,
+		nodeName: nodeName,
+		clock:    clock.RealClock{},
+		enc:      json.NewEncoder(w),
+	}
+}
+
+// SetClock replaces the clock timestamping the samples.
+func (r *RecordingLoadGetter) SetClock(c clock.Clock) {
+	r.clock = c
+}
+
+// GetLoad returns the load of the wrapped getter. Failing to write the
+// trace is logged, but does not fail the sample.
+func (r *RecordingLoadGetter) GetLoad() (Load, error) {
+	load, err := r.getter.GetLoad()
+
+	sample := LoadSample{
+		Version: LoadTraceVersion,
+		Time:    r.clock.Now().UTC(),
+		Node:    r.nodeName,
+	}
+	if err != nil {
+		sample.Error = err.Error()
+	} else {
+		sample.Load = &load
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if werr := r.enc.Encode(&sample); werr != nil {
+		glog.Errorf("could not write load trace: %s", werr.Error())
+	}
+
+	return load, err
+}
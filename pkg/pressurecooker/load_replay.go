@@ -0,0 +1,96 @@
+package pressurecooker
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrEndOfTrace is returned by ReplayLoadGetter once all samples were read.
+var ErrEndOfTrace = errors.New("end of load trace")
+
+// ReplayLoadGetter returns the samples of a load trace written by a
+// RecordingLoadGetter in order, one per call to GetLoad. Recorded errors
+// are returned as errors.
+type ReplayLoadGetter struct {
+	samples []LoadSample
+
+	mu   sync.Mutex
+	next int
+}
+
+// NewReplayLoadGetter reads a load trace. Empty lines are skipped, samples
+// must be in chronological order.
+func NewReplayLoadGetter(r io.Reader) (*ReplayLoadGetter, error) {
+	var samples []LoadSample
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var s LoadSample
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err.Error())
+		}
+		if s.Version != LoadTraceVersion {
+			return nil, fmt.Errorf("line %d: unsupported load trace version %d", line, s.Version)
+		}
+		if s.Load == nil && s.Error == "" {
+			return nil, fmt.Errorf("line %d: sample has neither load nor error", line)
+		}
+		if n := len(samples); n > 0 && s.Time.Before(samples[n-1].Time) {
+			return nil, fmt.Errorf("line %d: sample at %s is older than the previous one", line, s.Time)
+		}
+
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &ReplayLoadGetter{samples: samples}, nil
+}
+
+// Samples returns all samples of the trace.
+func (g *ReplayLoadGetter) Samples() []LoadSample {
+	return append([]LoadSample(nil), g.samples...)
+}
+
+// Next returns the time of the sample the next GetLoad returns, or false at
+// the end of the trace. Replays set their clock to it before sampling.
+func (g *ReplayLoadGetter) Next() (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next >= len(g.samples) {
+		return time.Time{}, false
+	}
+
+	return g.samples[g.next].Time, true
+}
+
+func (g *ReplayLoadGetter) GetLoad() (Load, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next >= len(g.samples) {
+		return Load{}, ErrEndOfTrace
+	}
+
+	s := g.samples[g.next]
+	g.next++
+
+	if s.Load == nil {
+		return Load{}, errors.New(s.Error)
+	}
+
+	return *s.Load, nil
+}
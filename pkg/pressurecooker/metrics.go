@@ -0,0 +1,81 @@
+package pressurecooker
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+var (
+	prometheusNamespace = "pressurecooker"
+	podsEvictedTotal    = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "pods_evicted_total",
+		Help:      "total number of pod evictions on this node by namespace, owner kind and outcome",
+	}, []string{"namespace", "owner_kind", "outcome"})
+	podsResizedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "pods_resized_total",
+		Help:      "total number of pod cpu request raises on this node by namespace, owner kind and outcome",
+	}, []string{"namespace", "owner_kind", "outcome"})
+	evictionCandidates = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Name:      "eviction_candidates",
+		Help:      "number of pods ranked for an eviction, all of them or only those that are not excluded",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200},
+	}, []string{"candidates"})
+	taintedSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: prometheusNamespace,
+		Name:      "tainted_seconds",
+		Help:      "time the node stayed tainted, observed when the taint is removed",
+		Buckets:   prometheus.ExponentialBuckets(60, 2, 10),
+	})
+	loadValue = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: prometheusNamespace,
+		Name:      "load",
+		Help:      "last sampled load by resource, source (psi or loadavg) and field (smallest, load1m or load5m)",
+	}, []string{"resource", "source", "field"})
+	watcherEventsDroppedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: prometheusNamespace,
+		Name:      "watcher_events_dropped_total",
+		Help:      "total number of watcher events replaced by a newer one before they were received, by type",
+	}, []string{"type"})
+)
+
+// RegisterMetrics registers the metrics of this package with r.
+func RegisterMetrics(r prometheus.Registerer) {
+	r.MustRegister(podsEvictedTotal)
+	r.MustRegister(podsResizedTotal)
+	r.MustRegister(evictionCandidates)
+	r.MustRegister(taintedSeconds)
+	r.MustRegister(loadValue)
+	r.MustRegister(watcherEventsDroppedTotal)
+}
+
+// ownerKindLabel returns the kind of the controller of pod, or "none".
+func ownerKindLabel(pod *v1.Pod) string {
+	for _, o := range pod.OwnerReferences {
+		if o.Controller != nil && *o.Controller {
+			return o.Kind
+		}
+	}
+
+	return "none"
+}
+
+// outcome classifies the result of an eviction or resize request.
+func outcome(err error, dryRun bool) string {
+	switch {
+	case dryRun:
+		return OutcomeDryRun
+	case err == nil:
+		return OutcomeSuccess
+	case errors.IsTooManyRequests(err):
+		// the eviction API rejects evictions that would violate a PodDisruptionBudget with 429
+		return OutcomePDBRejected
+	case errors.IsNotFound(err):
+		return OutcomeNotFound
+	default:
+		return OutcomeError
+	}
+}
@@ -0,0 +1,74 @@
+package pressurecooker
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+type NotificationType string
+
+const (
+	NotificationTaint    NotificationType = "taint"
+	NotificationUntaint  NotificationType = "untaint"
+	NotificationEviction NotificationType = "eviction"
+	NotificationResize   NotificationType = "resize"
+)
+
+// Notification tells people outside the cluster about a taint change or an
+// evicted pod. Notifications are not sent in dry-run mode.
+type Notification struct {
+	Time time.Time        `json:"time"`
+	Node string           `json:"node"`
+	Type NotificationType `json:"type"`
+	// "info" or "warning"
+	Level     string  `json:"level"`
+	Load      Load    `json:"load"`
+	Threshold float64 `json:"threshold"`
+	// namespace/name of the evicted or resized pod
+	Pod       string `json:"pod,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// kind/name of the workload the pod belongs to
+	Owner    string `json:"owner,omitempty"`
+	Reason   string `json:"reason"`
+	Incident string `json:"incident"`
+}
+
+// Notifier delivers notifications. Notify must not block.
+type Notifier interface {
+	Notify(n Notification)
+}
+
+// Text is a one-line, human readable summary of n.
+func (n Notification) Text() string {
+	cause := fmt.Sprintf("cpu load %.2f (threshold %.2f)", n.Load.Load5Min, n.Threshold)
+
+	switch n.Type {
+	case NotificationTaint:
+		return fmt.Sprintf("node %s was tainted due to %s", n.Node, cause)
+	case NotificationUntaint:
+		return fmt.Sprintf("node %s recovered, the taint was removed", n.Node)
+	case NotificationResize:
+		return fmt.Sprintf("the cpu requests of pod %s%s on node %s were raised due to %s", n.Pod, ownerSuffix(n.Owner), n.Node, cause)
+	default:
+		return fmt.Sprintf("pod %s%s was evicted from node %s due to %s", n.Pod, ownerSuffix(n.Owner), n.Node, cause)
+	}
+}
+
+func ownerSuffix(owner string) string {
+	if owner == "" {
+		return ""
+	}
+
+	return " (" + owner + ")"
+}
+
+func notify(n Notifier, clk clock.Clock, notification Notification) {
+	if n == nil {
+		return
+	}
+
+	notification.Time = clk.Now().UTC()
+	n.Notify(notification)
+}
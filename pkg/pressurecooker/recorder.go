@@ -0,0 +1,103 @@
+package pressurecooker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// Event reasons. Node events are recorded on the Node, pod events on the Pod
+// and the Node.
+const (
+	// node events
+	ReasonNodeTainted             = "NodeTainted"
+	ReasonNodeUntainted           = "NodeUntainted"
+	ReasonStaleTaintRemoved       = "StaleTaintRemoved"
+	ReasonTaintRemovedOnShutdown  = "TaintRemovedOnShutdown"
+	ReasonNodeUpdateFailed        = "NodeUpdateFailed"
+	ReasonNoEvictionCandidate     = "NoEvictionCandidate"
+	ReasonEvictionBudgetExhausted = "EvictionBudgetExhausted"
+	ReasonDisruptionLimitReached  = "DisruptionLimitReached"
+
+	// pod events
+	ReasonPodEvicted = "PodEvicted"
+	ReasonPodResized = "PodResized"
+)
+
+// IncidentAnnotation holds the incident ID on every Event.
+const IncidentAnnotation = "pressurecooker/incident"
+
+// Recorder records the Events of the tainter and the evicter. All Events of
+// one pressure episode, from the taint to the untaint, share an incident ID.
+type Recorder struct {
+	recorder record.EventRecorder
+	nodeRef  *v1.ObjectReference
+
+	mu       sync.Mutex
+	incident string
+}
+
+func NewRecorder(c kubernetes.Interface, nodeName string) (*Recorder, error) {
+	node, err := c.CoreV1().Nodes().Get(nodeName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	b := record.NewBroadcaster()
+	b.StartLogging(glog.Infof)
+	b.StartRecordingToSink(&typedv1.EventSinkImpl{
+		Interface: c.CoreV1().Events(""),
+	})
+
+	return &Recorder{
+		recorder: b.NewRecorder(scheme.Scheme, v1.EventSource{Host: nodeName, Component: ComponentName}),
+		nodeRef: &v1.ObjectReference{
+			Kind: "Node",
+			Name: nodeName,
+			UID:  node.UID,
+		},
+	}, nil
+}
+
+// Incident returns the ID of the current pressure episode and starts a new
+// one if there is none.
+func (r *Recorder) Incident() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.incident == "" {
+		r.incident = rand.String(10)
+	}
+
+	return r.incident
+}
+
+// EndIncident ends the current pressure episode.
+func (r *Recorder) EndIncident() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.incident = ""
+}
+
+// NodeEventf records an Event on the Node.
+func (r *Recorder) NodeEventf(eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(r.nodeRef, eventtype, reason, messageFmt, args...)
+}
+
+// Eventf records an Event on object, tagged with the current incident.
+func (r *Recorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	incident := r.Incident()
+
+	r.recorder.AnnotatedEventf(object, map[string]string{IncidentAnnotation: incident}, eventtype, reason,
+		"%s (incident %s)", fmt.Sprintf(messageFmt, args...), incident)
+}
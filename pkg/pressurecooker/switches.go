@@ -0,0 +1,150 @@
+package pressurecooker
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EnabledLabel disables pressurecooker on a node if set to "false".
+	EnabledLabel = "pressurecooker.enabled"
+
+	// The disabled-until annotations disable pressurecooker, tainting or
+	// evicting on a node until the given RFC3339 timestamp.
+	DisabledUntilAnnotation      = "pressurecooker/disabled-until"
+	TaintDisabledUntilAnnotation = "pressurecooker/taint-disabled-until"
+	EvictDisabledUntilAnnotation = "pressurecooker/evict-disabled-until"
+
+	// Keys of the kill switch ConfigMap; "false" disables the action on all nodes.
+	EnabledKey      = "enabled"
+	TaintEnabledKey = "taint-enabled"
+	EvictEnabledKey = "evict-enabled"
+)
+
+// Reasons for an action to be disabled, as reported in the enabled metric.
+const (
+	DisabledByConfig         = "config"
+	DisabledByNodeLabel      = "node-label"
+	DisabledByNodeAnnotation = "node-annotation"
+	DisabledByConfigMap      = "configmap"
+)
+
+// SwitchState holds the reason tainting and evicting are disabled, or ""
+// if they are enabled.
+type SwitchState struct {
+	Taint string
+	Evict string
+}
+
+func (s SwitchState) AllDisabled() bool {
+	return s.Taint != "" && s.Evict != ""
+}
+
+// Switches evaluates the disable switches of a node: the EnabledLabel, the
+// disabled-until annotations and an optional cluster-wide ConfigMap.
+type Switches struct {
+	client             kubernetes.Interface
+	nodeName           string
+	configMapNamespace string
+	configMapName      string
+	clock              clock.Clock
+	// set once a missing permission for the ConfigMap was logged
+	configMapForbidden bool
+}
+
+// NewSwitches creates the switches for nodeName. If configMapName is empty
+// there is no cluster-wide kill switch.
+func NewSwitches(client kubernetes.Interface, nodeName, configMapNamespace, configMapName string) *Switches {
+	return &Switches{
+		client:             client,
+		nodeName:           nodeName,
+		configMapNamespace: configMapNamespace,
+		configMapName:      configMapName,
+		clock:              clock.RealClock{},
+	}
+}
+
+// SetClock replaces the clock the disabled-until annotations are compared to.
+func (s *Switches) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *Switches) Check() (SwitchState, error) {
+	var state SwitchState
+
+	node, err := s.client.CoreV1().Nodes().Get(s.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return state, err
+	}
+
+	if v, ok := node.Labels[EnabledLabel]; ok && (v == "FALSE" || v == "false") {
+		state.Taint = DisabledByNodeLabel
+		state.Evict = DisabledByNodeLabel
+		return state, nil
+	}
+
+	if s.configMapName != "" {
+		cm, err := s.client.CoreV1().ConfigMaps(s.configMapNamespace).Get(s.configMapName, metav1.GetOptions{})
+		if errors.IsForbidden(err) {
+			// deployments without the permission treat the switch as absent
+			if !s.configMapForbidden {
+				glog.Warningf("not allowed to read kill switch ConfigMap %s/%s, ignoring it: %s", s.configMapNamespace, s.configMapName, err.Error())
+				s.configMapForbidden = true
+			}
+		} else if err != nil && !errors.IsNotFound(err) {
+			return state, err
+		}
+		if err == nil {
+			state = state.disableBy(DisabledByConfigMap, isFalse(cm, EnabledKey), isFalse(cm, TaintEnabledKey), isFalse(cm, EvictEnabledKey))
+		}
+	}
+
+	now := s.clock.Now()
+	state = state.disableBy(DisabledByNodeAnnotation,
+		isDisabledUntil(node, DisabledUntilAnnotation, now),
+		isDisabledUntil(node, TaintDisabledUntilAnnotation, now),
+		isDisabledUntil(node, EvictDisabledUntilAnnotation, now))
+
+	return state, nil
+}
+
+// disableBy records reason for every action that is disabled and not
+// already disabled for another reason.
+func (s SwitchState) disableBy(reason string, all, taint, evict bool) SwitchState {
+	if (all || taint) && s.Taint == "" {
+		s.Taint = reason
+	}
+	if (all || evict) && s.Evict == "" {
+		s.Evict = reason
+	}
+	return s
+}
+
+func isFalse(cm *v1.ConfigMap, key string) bool {
+	v := cm.Data[key]
+	return v == "FALSE" || v == "false"
+}
+
+// isDisabledUntil reports whether the node has the annotation with an
+// RFC 3339 timestamp that is after now. Malformed timestamps are logged and
+// ignored.
+func isDisabledUntil(node *v1.Node, annotation string, now time.Time) bool {
+	v, ok := node.Annotations[annotation]
+	if !ok {
+		return false
+	}
+
+	until, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		glog.Warningf("ignoring annotation %s=%q on node %s: %s", annotation, v, node.Name, err.Error())
+		return false
+	}
+
+	return now.Before(until)
+}
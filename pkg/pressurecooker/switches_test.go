@@ -0,0 +1,29 @@
+package pressurecooker
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestSwitchesConfigMapForbidden(t *testing.T) {
+	client := fake.NewSimpleClientset(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}})
+	client.PrependReactor("get", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "pressurecooker", nil)
+	})
+
+	s := NewSwitches(client, "node-1", "kube-system", "pressurecooker")
+	state, err := s.Check()
+	if err != nil {
+		t.Fatalf("forbidden kill switch ConfigMap failed the check: %s", err.Error())
+	}
+	if state != (SwitchState{}) {
+		t.Errorf("state = %+v, want everything enabled", state)
+	}
+}
@@ -0,0 +1,155 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TaintExpiryAnnotation marks a taint that was left behind on shutdown. The
+// next instance adopts the taint until the given RFC3339 time and removes it
+// once that time has passed.
+const TaintExpiryAnnotation = "pressurecooker/taint-expires"
+
+type ShutdownAction string
+
+const (
+	// ShutdownActionRemove removes the taint on shutdown.
+	ShutdownActionRemove ShutdownAction = "remove"
+	// ShutdownActionExpire leaves the taint with an expiry annotation.
+	ShutdownActionExpire ShutdownAction = "expire"
+	// ShutdownActionKeep leaves the taint as it is.
+	ShutdownActionKeep ShutdownAction = "keep"
+)
+
+func ParseShutdownAction(s string) (ShutdownAction, error) {
+	switch a := ShutdownAction(s); a {
+	case ShutdownActionRemove, ShutdownActionExpire, ShutdownActionKeep:
+		return a, nil
+	}
+
+	return "", fmt.Errorf("unknown shutdown action %q", s)
+}
+
+// Shutdown hands the taint over according to action. expiry is only used
+// with ShutdownActionExpire.
+func (t *Tainter) Shutdown(action ShutdownAction, expiry time.Duration) error {
+	switch action {
+	case ShutdownActionKeep:
+		return nil
+	case ShutdownActionExpire:
+		return t.setTaintExpiry(t.clock.Now().Add(expiry))
+	}
+
+	node, err := t.client.CoreV1().Nodes().Get(t.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if t.dryRun {
+		if t.taintIndex(node) != -1 {
+			glog.Infof("%sremoving taint from node %s on shutdown", dryRunPrefix, t.nodeName)
+		}
+		return nil
+	}
+
+	return t.removeTaint(node, ReasonTaintRemovedOnShutdown, "pressurecooker is shutting down, untainting node")
+}
+
+// ReconcileStaleTaint takes over a taint that was left behind by a previous
+// instance. Taints whose expiry has passed are removed, all others are kept
+// and handled like a taint added by this instance.
+func (t *Tainter) ReconcileStaleTaint() error {
+	node, err := t.client.CoreV1().Nodes().Get(t.nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	raw, ok := node.Annotations[TaintExpiryAnnotation]
+	if !ok {
+		return nil
+	}
+
+	expires, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		glog.Warningf("treating unreadable %s annotation %q on node %s as expired: %s", TaintExpiryAnnotation, raw, t.nodeName, err.Error())
+	}
+
+	if t.dryRun {
+		if err != nil || t.clock.Now().After(expires) {
+			glog.Infof("%sremoving stale taint from node %s", dryRunPrefix, t.nodeName)
+		}
+		return nil
+	}
+
+	if err != nil || t.clock.Now().After(expires) {
+		if err := t.removeTaint(node, ReasonStaleTaintRemoved, fmt.Sprintf("taint left behind by a previous instance expired at %s, untainting node", raw)); err != nil {
+			return err
+		}
+	} else {
+		glog.Infof("adopting taint on node %s left behind by a previous instance", t.nodeName)
+	}
+
+	return t.patchTaintExpiry(nil)
+}
+
+func (t *Tainter) setTaintExpiry(expires time.Time) error {
+	tainted, err := t.IsNodeTainted()
+	if err != nil || !tainted {
+		return err
+	}
+
+	value := expires.UTC().Format(time.RFC3339)
+
+	if t.dryRun {
+		glog.Infof("%sleaving taint on node %s until %s", dryRunPrefix, t.nodeName, value)
+		return nil
+	}
+
+	glog.Infof("leaving taint on node %s until %s", t.nodeName, value)
+
+	return t.patchTaintExpiry(&value)
+}
+
+// patchTaintExpiry sets the expiry annotation, or removes it if value is nil.
+func (t *Tainter) patchTaintExpiry(value *string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]*string{
+				TaintExpiryAnnotation: value,
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = t.client.CoreV1().Nodes().Patch(t.nodeName, types.MergePatchType, patch)
+	return err
+}
+
+// removeTaint is used outside of the regular untainting, so it records its
+// own event reason.
+func (t *Tainter) removeTaint(node *v1.Node, reason, message string) error {
+	taintIndex := t.taintIndex(node)
+	if taintIndex == -1 {
+		return nil
+	}
+
+	glog.Infof("%s: %s", node.Name, message)
+	t.recorder.NodeEventf(v1.EventTypeNormal, reason, "%s", message)
+
+	err := t.patchRemoveTaint(taintIndex)
+	t.auditTaint(AuditUntaint, ThresholdEvent{}, reason, err)
+	if err == nil {
+		t.notify(NotificationUntaint, "info", ThresholdEvent{}, reason)
+		t.recorder.EndIncident()
+	}
+
+	return err
+}
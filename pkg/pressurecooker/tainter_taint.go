@@ -2,9 +2,12 @@ package pressurecooker
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/rtreffer/kubernetes-pressurecooker/pkg/jsonpatch"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -17,7 +20,7 @@ func (t *Tainter) IsNodeTainted() (bool, error) {
 	}
 
 	for i := range node.Spec.Taints {
-		if node.Spec.Taints[i].Key == TaintKey {
+		if node.Spec.Taints[i].Key == t.taint.Key {
 			return true, nil
 		}
 	}
@@ -25,28 +28,31 @@ func (t *Tainter) IsNodeTainted() (bool, error) {
 	return false, nil
 }
 
-func (t *Tainter) IsPressurecookerDisabled() (bool, error) {
+func (t *Tainter) NodeLabels() (map[string]string, error) {
 	node, err := t.client.CoreV1().Nodes().Get(t.nodeName, metav1.GetOptions{})
 	if err != nil {
-		return false, err
-	}
-
-	for k, v := range node.Labels {
-		if k != "pressurecooker.enabled" {
-			continue
-		}
-		if v == "FALSE" || v == "false" {
-			return true, nil
-		}
+		return nil, err
 	}
 
-	return false, nil
+	return node.Labels, nil
 }
 
-func (t *Tainter) TaintNode(evt ThresholdEvent) error {
+// TaintNode adds the taint to the node. It reports whether the node carries
+// the taint afterwards, which is not the case while tainting is disabled or
+// in dry-run mode.
+func (t *Tainter) TaintNode(evt ThresholdEvent) (tainted bool, err error) {
+	_, span := tracer.Start(evt.context(), "tainter.taint", trace.WithAttributes(loadAttributes(evt.Load, evt.Threshold)...))
+	span.SetAttributes(attribute.Bool("pressurecooker.dry_run", t.dryRun))
+	defer func() { finishSpan(span, err) }()
+
+	if t.disabled {
+		glog.Infof("tainting is disabled, not tainting node %s", t.nodeName)
+		return false, nil
+	}
+
 	node, err := t.client.CoreV1().Nodes().Get(t.nodeName, metav1.GetOptions{})
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	nodeCopy := node.DeepCopy()
@@ -56,56 +62,96 @@ func (t *Tainter) TaintNode(evt ThresholdEvent) error {
 	}
 
 	for i := range nodeCopy.Spec.Taints {
-		if nodeCopy.Spec.Taints[i].Key == TaintKey {
+		if nodeCopy.Spec.Taints[i].Key == t.taint.Key {
 			glog.Infof("wanted to taint node %s, but taint already exists", nodeCopy.Name)
-			return nil
+			return true, nil
 		}
 	}
 
-	nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, v1.Taint{
-		Key:    TaintKey,
-		Value:  "true",
-		Effect: v1.TaintEffectPreferNoSchedule,
-	})
+	nodeCopy.Spec.Taints = append(nodeCopy.Spec.Taints, t.taint)
+
+	if t.dryRun {
+		glog.Infof("%stainting node %s", dryRunPrefix, nodeCopy.Name)
+		t.recorder.NodeEventf(v1.EventTypeWarning, ReasonNodeTainted, "%s%s, tainting node", dryRunPrefix, evt.String())
+		t.auditTaint(AuditTaint, evt, ReasonNodeTainted, nil)
+		return false, nil
+	}
 
 	_, err = t.client.CoreV1().Nodes().Update(nodeCopy)
 
-	t.recorder.Eventf(t.nodeRef, v1.EventTypeWarning, "CPUPressureExceeded", "%s, tainting node", evt.String())
+	t.recorder.NodeEventf(v1.EventTypeWarning, ReasonNodeTainted, "%s, tainting node", evt.String())
+	t.auditTaint(AuditTaint, evt, ReasonNodeTainted, err)
 
 	if err != nil {
-		t.recorder.Eventf(t.nodeRef, v1.EventTypeWarning, "NodePatchError", "could not patch node: %s", err.Error())
-		return err
+		t.recorder.NodeEventf(v1.EventTypeWarning, ReasonNodeUpdateFailed, "could not patch node: %s", err.Error())
+		return false, err
 	}
 
-	return nil
+	t.taintedSince = t.clock.Now()
+	t.notify(NotificationTaint, "warning", evt, ReasonNodeTainted)
+
+	return true, nil
 }
 
-func (t *Tainter) UntaintNode(evt ThresholdEvent) error {
+func (t *Tainter) UntaintNode(evt ThresholdEvent) (err error) {
+	_, span := tracer.Start(evt.context(), "tainter.untaint", trace.WithAttributes(loadAttributes(evt.Load, evt.Threshold)...))
+	span.SetAttributes(attribute.Bool("pressurecooker.dry_run", t.dryRun))
+	defer func() { finishSpan(span, err) }()
+
 	node, err := t.client.CoreV1().Nodes().Get(t.nodeName, metav1.GetOptions{})
 	if err != nil {
 		return err
 	}
 
-	taintIndex := -1
+	taintIndex := t.taintIndex(node)
 
-	for i, t := range node.Spec.Taints {
-		if t.Key == TaintKey {
-			taintIndex = i
-			break
+	if t.dryRun {
+		// a dry run never adds the taint, but it may have been left behind by
+		// an earlier instance that was not in dry run
+		if taintIndex == -1 {
+			glog.Infof("%suntainting node %s, which does not carry the taint", dryRunPrefix, node.Name)
+		} else {
+			glog.Infof("%swould remove taint from node %s", dryRunPrefix, node.Name)
 		}
+		t.recorder.NodeEventf(v1.EventTypeNormal, ReasonNodeUntainted, "%s%s, untainting node", dryRunPrefix, evt.String())
+		t.auditTaint(AuditUntaint, evt, ReasonNodeUntainted, nil)
+		t.recorder.EndIncident()
+		return nil
 	}
 
 	if taintIndex == -1 {
 		glog.Infof("wanted to remove taint from node %s, but taint was already gone", node.Name)
+		t.recorder.EndIncident()
 		return nil
 	}
 
-	t.recorder.Eventf(t.nodeRef, v1.EventTypeNormal, "LoadThresholdDeceeded", "%s. untainting node", evt.String())
+	t.recorder.NodeEventf(v1.EventTypeNormal, ReasonNodeUntainted, "%s, untainting node", evt.String())
+
+	err = t.patchRemoveTaint(taintIndex)
+	t.auditTaint(AuditUntaint, evt, ReasonNodeUntainted, err)
+	if err == nil {
+		t.notify(NotificationUntaint, "info", evt, ReasonNodeUntainted)
+		t.recorder.EndIncident()
+	}
+
+	return err
+}
 
-	_, err = t.client.CoreV1().Nodes().Patch(t.nodeName, types.JSONPatchType, jsonpatch.PatchList{{
+func (t *Tainter) taintIndex(node *v1.Node) int {
+	for i, taint := range node.Spec.Taints {
+		if taint.Key == t.taint.Key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (t *Tainter) patchRemoveTaint(taintIndex int) error {
+	_, err := t.client.CoreV1().Nodes().Patch(t.nodeName, types.JSONPatchType, jsonpatch.PatchList{{
 		Op:    "test",
 		Path:  fmt.Sprintf("/spec/taints/%d/key", taintIndex),
-		Value: TaintKey,
+		Value: t.taint.Key,
 	}, {
 		Op:    "remove",
 		Path:  fmt.Sprintf("/spec/taints/%d", taintIndex),
@@ -113,9 +159,14 @@ func (t *Tainter) UntaintNode(evt ThresholdEvent) error {
 	}}.ToJSON())
 
 	if err != nil {
-		t.recorder.Eventf(t.nodeRef, v1.EventTypeWarning, "NodePatchError", "could not patch node: %s", err.Error())
+		t.recorder.NodeEventf(v1.EventTypeWarning, ReasonNodeUpdateFailed, "could not patch node: %s", err.Error())
 		return err
 	}
 
+	if !t.taintedSince.IsZero() {
+		taintedSeconds.Observe(t.clock.Since(t.taintedSince).Seconds())
+		t.taintedSince = time.Time{}
+	}
+
 	return nil
 }
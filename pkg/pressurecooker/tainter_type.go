@@ -1,46 +1,101 @@
 package pressurecooker
 
 import (
-	"github.com/golang/glog"
+	"time"
+
 	"k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	typedv1 "k8s.io/client-go/kubernetes/typed/core/v1"
-	"k8s.io/client-go/tools/record"
 )
 
 const ComponentName = "pressurecooker"
 
 const TaintKey = "pressurecooker/load-exceeded"
 
+func DefaultTaint() v1.Taint {
+	return v1.Taint{
+		Key:    TaintKey,
+		Value:  "true",
+		Effect: v1.TaintEffectPreferNoSchedule,
+	}
+}
+
 type Tainter struct {
 	client   kubernetes.Interface
-	recorder record.EventRecorder
+	recorder *Recorder
 	nodeName string
-	nodeRef  *v1.ObjectReference
+	taint    v1.Taint
+	dryRun   bool
+	disabled bool
+	audit    *AuditLog
+	notifier Notifier
+	// zero unless the taint was added by this instance
+	taintedSince time.Time
+	clock        clock.Clock
 }
 
-func NewTainter(c kubernetes.Interface, nodeName string) (*Tainter, error) {
-	b := record.NewBroadcaster()
-	b.StartLogging(glog.Infof)
-	b.StartRecordingToSink(&typedv1.EventSinkImpl{
-		Interface: c.CoreV1().Events(""),
-	})
-
-	r := b.NewRecorder(scheme.Scheme, v1.EventSource{Host: nodeName, Component: ComponentName + "/tainter"})
-
-	nodeRef := &v1.ObjectReference{
-		Kind:      "Node",
-		Name:      nodeName,
-		UID:       types.UID(nodeName),
-		Namespace: "",
-	}
-
+func NewTainter(c kubernetes.Interface, r *Recorder, nodeName string) (*Tainter, error) {
 	return &Tainter{
 		client:   c,
 		recorder: r,
 		nodeName: nodeName,
-		nodeRef:  nodeRef,
+		taint:    DefaultTaint(),
+		clock:    clock.RealClock{},
 	}, nil
 }
+
+// SetDryRun makes the tainter report taint changes without updating the node.
+func (t *Tainter) SetDryRun(dryRun bool) {
+	t.dryRun = dryRun
+}
+
+// SetClock replaces the clock used for taint expiries and durations.
+func (t *Tainter) SetClock(c clock.Clock) {
+	t.clock = c
+}
+
+// SetTaint replaces the taint that is added to the node under pressure.
+func (t *Tainter) SetTaint(taint v1.Taint) {
+	t.taint = taint
+}
+
+// SetEnabled turns tainting on or off. While disabled, TaintNode does
+// nothing, but UntaintNode still removes an existing taint.
+func (t *Tainter) SetEnabled(enabled bool) {
+	t.disabled = !enabled
+}
+
+// SetAuditLog records every taint change to a.
+func (t *Tainter) SetAuditLog(a *AuditLog) {
+	t.audit = a
+}
+
+// SetNotifier sends a notification to n whenever the node is tainted or
+// untainted.
+func (t *Tainter) SetNotifier(n Notifier) {
+	t.notifier = n
+}
+
+func (t *Tainter) notify(typ NotificationType, level string, evt ThresholdEvent, reason string) {
+	notify(t.notifier, t.clock, Notification{
+		Node:      t.nodeName,
+		Type:      typ,
+		Level:     level,
+		Load:      evt.Load,
+		Threshold: evt.Threshold,
+		Reason:    reason,
+		Incident:  t.recorder.Incident(),
+	})
+}
+
+func (t *Tainter) auditTaint(typ AuditEntryType, evt ThresholdEvent, reason string, err error) {
+	t.audit.Log(AuditEntry{
+		Type:      typ,
+		DryRun:    t.dryRun,
+		Load:      auditLoad(evt),
+		Threshold: evt.Threshold,
+		Reason:    reason,
+		Incident:  t.recorder.Incident(),
+		Error:     errorString(err),
+	})
+}
@@ -0,0 +1,72 @@
+package pressurecooker
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	v1 "k8s.io/api/core/v1"
+)
+
+// number of candidates added to the eviction span
+const tracedCandidates = 10
+
+var tracer = otel.Tracer("github.com/rtreffer/kubernetes-pressurecooker/pkg/pressurecooker")
+
+// context returns a context with the span of the watcher tick that sent evt,
+// so that spans for handling the event become its children.
+func (evt ThresholdEvent) context() context.Context {
+	return trace.ContextWithSpanContext(context.Background(), evt.SpanContext)
+}
+
+func finishSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func loadAttributes(l Load, threshold float64) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("pressurecooker.load.source", l.Source),
+		attribute.Float64("pressurecooker.load.smallest", l.Smallest),
+		attribute.Float64("pressurecooker.load.load1m", l.Load1Min),
+		attribute.Float64("pressurecooker.load.load5m", l.Load5Min),
+		attribute.Float64("pressurecooker.threshold", threshold),
+	}
+}
+
+func candidateAttributes(s PodCandidateSet, selected *v1.Pod) []attribute.KeyValue {
+	ranking := make([]string, 0, tracedCandidates)
+	eligible := 0
+	for i := range s {
+		if !s[i].Excluded() {
+			eligible++
+		}
+		if i < tracedCandidates {
+			ranking = append(ranking, fmt.Sprintf("%s/%s=%d", s[i].Pod.Namespace, s[i].Pod.Name, s[i].Score))
+		}
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.Int("pressurecooker.candidates", len(s)),
+		attribute.Int("pressurecooker.candidates.eligible", eligible),
+		attribute.StringSlice("pressurecooker.ranking", ranking),
+	}
+
+	for i := range s {
+		if s[i].Pod != selected {
+			continue
+		}
+		attrs = append(attrs, attribute.Int("pressurecooker.score", s[i].Score))
+		for rule, score := range s[i].Scores {
+			attrs = append(attrs, attribute.Int("pressurecooker.scores."+rule, score))
+		}
+	}
+
+	return attrs
+}
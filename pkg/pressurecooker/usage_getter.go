@@ -0,0 +1,62 @@
+package pressurecooker
+
+import (
+	"encoding/json"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PodUsage maps pod UIDs to their current CPU usage in millicores.
+type PodUsage map[types.UID]int64
+
+type PodUsageGetter interface {
+	GetPodUsage() (PodUsage, error)
+}
+
+// KubeletSummaryUsageGetter reads per-pod CPU usage from the kubelet summary
+// API, proxied through the apiserver (requires get on nodes/proxy).
+type KubeletSummaryUsageGetter struct {
+	Client   kubernetes.Interface
+	NodeName string
+}
+
+type kubeletSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string    `json:"name"`
+			Namespace string    `json:"namespace"`
+			UID       types.UID `json:"uid"`
+		} `json:"podRef"`
+		CPU *struct {
+			UsageNanoCores *uint64 `json:"usageNanoCores"`
+		} `json:"cpu"`
+	} `json:"pods"`
+}
+
+func (g *KubeletSummaryUsageGetter) GetPodUsage() (PodUsage, error) {
+	raw, err := g.Client.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(g.NodeName).
+		SubResource("proxy").
+		Suffix("stats/summary").
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	var summary kubeletSummary
+	if err := json.Unmarshal(raw, &summary); err != nil {
+		return nil, err
+	}
+
+	usage := make(PodUsage, len(summary.Pods))
+	for _, p := range summary.Pods {
+		if p.CPU == nil || p.CPU.UsageNanoCores == nil {
+			continue
+		}
+		usage[p.PodRef.UID] = int64(*p.CPU.UsageNanoCores / 1000000)
+	}
+
+	return usage, nil
+}
@@ -1,23 +1,215 @@
 package pressurecooker
 
 import (
-	"time"
+	"context"
 
 	"github.com/golang/glog"
+	"go.opentelemetry.io/otel/attribute"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
 func (w *Watcher) SetAsHigh(high bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
 	w.isCurrentlyHigh = high
 }
 
+// SetThreshold changes the threshold, also while Run is active.
+func (w *Watcher) SetThreshold(threshold float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.Threshold = threshold
+}
+
+// SetClock replaces the clock driving the ticker and timestamping samples.
+// It must be called before Run.
+func (w *Watcher) SetClock(c clock.Clock) {
+	w.clock = c
+}
+
+// SetAuditLog records every change between high and low load to a. It must
+// be called before Run.
+func (w *Watcher) SetAuditLog(a *AuditLog) {
+	w.audit = a
+}
+
+func (w *Watcher) auditState(state string, load Load, threshold float64) {
+	w.audit.Log(AuditEntry{
+		Type:      AuditState,
+		Load:      &load,
+		Threshold: threshold,
+		State:     state,
+	})
+}
+
+// Status returns the last sample and the recent threshold events.
+func (w *Watcher) Status() WatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return WatcherStatus{
+		Load:      w.lastLoad,
+		SampledAt: w.lastSample,
+		TickedAt:  w.lastTick,
+		LastError: errorString(w.lastError),
+		High:      w.isCurrentlyHigh,
+		Threshold: w.Threshold,
+		Events:    append([]ThresholdRecord(nil), w.events...),
+	}
+}
+
+func (w *Watcher) recordTick(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastTick = w.clock.Now()
+	w.lastError = err
+}
+
+// evaluate records a sample and returns the event to send, if any, with
+// its type: ThresholdExceeded, ThresholdDeceeded or empty.
+func (w *Watcher) evaluate(load Load) (ThresholdEvent, string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	threshold := w.Threshold
+	w.lastLoad = load
+	w.lastSample = w.clock.Now()
+	exportLoad(load)
+
+	glog.Infof("current state: high_load=%t %v threshold=%.2f",
+		w.isCurrentlyHigh, load, threshold)
+
+	evt := ThresholdEvent{Load: load, Threshold: threshold}
+	typ := ""
+
+	if load.Load5Min >= threshold {
+		if !w.isCurrentlyHigh {
+			w.isCurrentlyHigh = true
+			w.auditState("high", load, threshold)
+			w.recordTransition(ThresholdExceeded, load, threshold)
+			typ = ThresholdExceeded
+		} else if load.Load1Min >= threshold && load.Smallest >= threshold {
+			typ = ThresholdExceeded
+		}
+	} else if load.Load5Min < threshold && load.Load1Min < threshold && load.Smallest < threshold {
+		if w.isCurrentlyHigh {
+			w.auditState("low", load, threshold)
+			w.recordTransition(ThresholdDeceeded, load, threshold)
+		}
+		w.isCurrentlyHigh = false
+		typ = ThresholdDeceeded
+	}
+
+	return evt, typ
+}
+
+// recordTransition keeps a change between low and high load for Status.
+// Events sent on every tick are not kept, they would push the transitions
+// out within minutes.
+func (w *Watcher) recordTransition(typ string, load Load, threshold float64) {
+	w.events = append(w.events, ThresholdRecord{
+		Time:      w.lastSample,
+		Type:      typ,
+		Load:      load,
+		Threshold: threshold,
+	})
+	if len(w.events) > thresholdHistoryLength {
+		w.events = w.events[len(w.events)-thresholdHistoryLength:]
+	}
+}
+
+// Sample reads the load once and returns the resulting event with its
+// type: ThresholdExceeded, ThresholdDeceeded or empty if the load is in
+// between. Run samples on every tick; tests and replays call Sample
+// directly to step through a load sequence without waiting for the ticker.
+func (w *Watcher) Sample() (ThresholdEvent, string, error) {
+	_, span := tracer.Start(context.Background(), "watcher.tick")
+
+	load, err := w.LoadGetter.GetLoad()
+	w.recordTick(err)
+	if err != nil {
+		finishSpan(span, err)
+		return ThresholdEvent{}, "", err
+	}
+
+	evt, typ := w.evaluate(load)
+	span.SetAttributes(loadAttributes(load, evt.Threshold)...)
+	span.SetAttributes(attribute.String("pressurecooker.event", typ))
+	evt.SpanContext = span.SpanContext()
+	span.End()
+
+	return evt, typ, nil
+}
+
+// sendLatest sends evt on ch without blocking. An event that was not
+// received yet is replaced, as only the latest state matters. Events of the
+// opposite type are discarded, so a receiver never acts on an older state
+// after a newer one.
+func sendLatest(ch, opposite chan ThresholdEvent, typ string, evt ThresholdEvent) {
+	select {
+	case <-opposite:
+		watcherEventsDroppedTotal.WithLabelValues(oppositeType(typ)).Inc()
+	default:
+	}
+
+	for {
+		select {
+		case ch <- evt:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
+			watcherEventsDroppedTotal.WithLabelValues(typ).Inc()
+		default:
+		}
+	}
+}
+
+func oppositeType(typ string) string {
+	if typ == ThresholdExceeded {
+		return ThresholdDeceeded
+	}
+
+	return ThresholdExceeded
+}
+
+// sendError sends err on ch without blocking, replacing an error that was
+// not received yet.
+func sendError(ch chan error, err error) {
+	for {
+		select {
+		case ch <- err:
+			return
+		default:
+		}
+
+		select {
+		case <-ch:
+			watcherEventsDroppedTotal.WithLabelValues("error").Inc()
+		default:
+		}
+	}
+}
+
+// Run samples the load every TickerInterval until closeChan is closed. The
+// returned channels hold only the latest event of each kind: sampling never
+// waits for the receiver, so a slow taint or eviction does not delay the
+// next sample and an event that was not received in time is replaced by a
+// newer one. All channels are closed when Run stops.
 func (w *Watcher) Run(closeChan chan struct{}) (<-chan ThresholdEvent, <-chan ThresholdEvent, <-chan error) {
-	exceeded := make(chan ThresholdEvent)
-	deceeded := make(chan ThresholdEvent)
-	errs := make(chan error)
-	ticker := time.NewTicker(w.TickerInterval)
+	exceeded := make(chan ThresholdEvent, 1)
+	deceeded := make(chan ThresholdEvent, 1)
+	errs := make(chan error, 1)
+	ticker := w.clock.NewTicker(w.TickerInterval)
 
 	go func() {
 		defer func() {
+			ticker.Stop()
 			close(exceeded)
 			close(deceeded)
 			close(errs)
@@ -25,26 +217,18 @@ func (w *Watcher) Run(closeChan chan struct{}) (<-chan ThresholdEvent, <-chan Th
 
 		for {
 			select {
-			case <-ticker.C:
-				load, err := w.LoadGetter.GetLoad()
+			case <-ticker.C():
+				evt, typ, err := w.Sample()
 				if err != nil {
-					errs <- err
+					sendError(errs, err)
 					continue
 				}
 
-				glog.Infof("current state: high_load=%t %v threshold=%.2f",
-					w.isCurrentlyHigh, load, w.Threshold)
-
-				if load.Load5Min >= w.Threshold {
-					if !w.isCurrentlyHigh {
-						w.isCurrentlyHigh = true
-						exceeded <- ThresholdEvent{Load: load, Threshold: w.Threshold}
-					} else if load.Load1Min >= w.Threshold && load.Smallest >= w.Threshold {
-						exceeded <- ThresholdEvent{Load: load, Threshold: w.Threshold}
-					}
-				} else if load.Load5Min < w.Threshold && load.Load1Min < w.Threshold && load.Smallest < w.Threshold {
-					w.isCurrentlyHigh = false
-					deceeded <- ThresholdEvent{Load: load, Threshold: w.Threshold}
+				switch typ {
+				case ThresholdExceeded:
+					sendLatest(exceeded, deceeded, typ, evt)
+				case ThresholdDeceeded:
+					sendLatest(deceeded, exceeded, typ, evt)
 				}
 			case <-closeChan:
 				return
@@ -0,0 +1,106 @@
+package pressurecooker
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// steppedLoad returns loads in order and then an error. Every call is
+// reported on calls, which blocks the watcher until the test receives it.
+type steppedLoad struct {
+	loads []float64
+	calls chan int
+	n     int
+}
+
+func (s *steppedLoad) GetLoad() (Load, error) {
+	s.calls <- s.n
+	defer func() { s.n++ }()
+
+	if s.n >= len(s.loads) {
+		return Load{}, errors.New("no more loads")
+	}
+	load := s.loads[s.n]
+
+	return Load{Source: "psi", Smallest: load, Load1Min: load, Load5Min: load}, nil
+}
+
+func TestWatcherStatusKeepsTransitions(t *testing.T) {
+	w, err := NewWatcher(25, nil)
+	if err != nil {
+		t.Fatalf("could not create watcher: %s", err.Error())
+	}
+
+	var loads []float64
+	// more ticks above and below the threshold than the history holds
+	for i := 0; i < 2*thresholdHistoryLength; i++ {
+		loads = append(loads, 10)
+	}
+	for i := 0; i < 2*thresholdHistoryLength; i++ {
+		loads = append(loads, 30)
+	}
+	loads = append(loads, 10, 10)
+
+	for _, load := range loads {
+		w.evaluate(Load{Source: "psi", Smallest: load, Load1Min: load, Load5Min: load})
+	}
+
+	var types []string
+	for _, e := range w.Status().Events {
+		types = append(types, e.Type)
+	}
+	if want := []string{ThresholdExceeded, ThresholdDeceeded}; !reflect.DeepEqual(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+}
+
+func TestWatcherRunDoesNotWaitForReceiver(t *testing.T) {
+	lg := &steppedLoad{loads: []float64{30, 31, 32, 33, 34}, calls: make(chan int)}
+	w, err := NewWatcher(25, lg)
+	if err != nil {
+		t.Fatalf("could not create watcher: %s", err.Error())
+	}
+	c := clock.NewFakeClock(time.Date(2020, time.March, 12, 3, 0, 0, 0, time.UTC))
+	w.SetClock(c)
+
+	closeChan := make(chan struct{})
+	defer close(closeChan)
+	exceeded, _, _ := w.Run(closeChan)
+
+	// nothing receives from exceeded while the clock advances; every tick
+	// above the threshold sends an event. The last call fails, so once it
+	// is made all events have been sent.
+	for i := 0; i <= len(lg.loads); i++ {
+		c.Step(w.TickerInterval)
+		select {
+		case n := <-lg.calls:
+			if n != i {
+				t.Fatalf("sample %d, want %d", n, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("sample %d was not taken while the receiver was blocked", i)
+		}
+	}
+
+	if sampled, want := w.Status().SampledAt, c.Now().Add(-w.TickerInterval); !sampled.Equal(want) {
+		t.Errorf("sampled at %s, want %s", sampled, want)
+	}
+
+	select {
+	case evt := <-exceeded:
+		if want := lg.loads[len(lg.loads)-1]; evt.Load.Load5Min != want {
+			t.Errorf("received load %v, want the latest %v", evt.Load.Load5Min, want)
+		}
+	default:
+		t.Fatalf("no event was delivered")
+	}
+	select {
+	case evt := <-exceeded:
+		t.Errorf("received a second event with load %v, want only the latest", evt.Load.Load5Min)
+	default:
+	}
+}
@@ -2,24 +2,72 @@ package pressurecooker
 
 import (
 	"fmt"
+	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/util/clock"
 )
 
 type ThresholdEvent struct {
 	Load      Load
 	Threshold float64
+	// span of the watcher tick that sent the event
+	SpanContext trace.SpanContext
 }
 
 func (t ThresholdEvent) String() string {
 	return fmt.Sprintf("load=%v threshold=%.2f", t.Load, t.Threshold)
 }
 
+// types of threshold events
+const (
+	ThresholdExceeded = "exceeded"
+	ThresholdDeceeded = "deceeded"
+)
+
+// number of transitions kept for Status
+const thresholdHistoryLength = 20
+
+// ThresholdRecord is a transition between low and high load.
+type ThresholdRecord struct {
+	Time time.Time `json:"time"`
+	// ThresholdExceeded or ThresholdDeceeded
+	Type      string  `json:"type"`
+	Load      Load    `json:"load"`
+	Threshold float64 `json:"threshold"`
+}
+
+// WatcherStatus is a snapshot of the watcher state.
+type WatcherStatus struct {
+	Load      Load      `json:"load"`
+	SampledAt time.Time `json:"sampledAt"`
+	// last tick, successful or not
+	TickedAt  time.Time         `json:"tickedAt"`
+	LastError string            `json:"lastError,omitempty"`
+	High      bool              `json:"high"`
+	Threshold float64           `json:"threshold"`
+	Events    []ThresholdRecord `json:"events"`
+}
+
 type Watcher struct {
 	TickerInterval time.Duration
 	Threshold      float64
 	LoadGetter     LoadGetter
 
 	isCurrentlyHigh bool
+	audit           *AuditLog
+	clock           clock.Clock
+
+	lastLoad   Load
+	lastSample time.Time
+	lastTick   time.Time
+	lastError  error
+	events     []ThresholdRecord
+
+	// guards Threshold, isCurrentlyHigh, the last sample and the events
+	// while Run is active
+	mu sync.Mutex
 }
 
 func NewWatcher(threshold float64, loadGetter LoadGetter) (*Watcher, error) {
@@ -31,5 +79,6 @@ func NewWatcher(threshold float64, loadGetter LoadGetter) (*Watcher, error) {
 		Threshold:      threshold,
 		TickerInterval: 15 * time.Second,
 		LoadGetter:     loadGetter,
+		clock:          clock.RealClock{},
 	}, nil
 }